@@ -0,0 +1,142 @@
+// Package captcha 图片验证码，答案只保存在服务端内存中，每个验证码只能校验一次
+package captcha
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// Length 验证码的数字个数
+	Length = 5
+	// Expiration 验证码的有效期
+	Expiration = 10 * time.Minute
+	// collectNum 每生成多少个验证码清理一次过期的记录
+	collectNum = 100
+	// maxEntries 最多同时保存的验证码，超出时先清理过期的，仍然超出时丢弃最早过期的
+	maxEntries = 10000
+)
+
+// ErrNotFound 验证码不存在或已过期
+var ErrNotFound = errors.New("验证码不存在或已过期")
+
+type entry struct {
+	digits  []byte
+	expires time.Time
+}
+
+var store = struct {
+	sync.Mutex
+	entries map[string]*entry
+	count   int
+}{
+	entries: map[string]*entry{},
+}
+
+// randomDigits 生成随机数字，每个元素为 0 到 9
+func randomDigits(n int) []byte {
+	b := make([]byte, n)
+	for i := 0; i < n; {
+		if _, err := rand.Read(b[i:]); err != nil {
+			panic(err)
+		}
+		// 丢弃 250 及以上的值，保证每个数字出现的概率相同
+		for j := i; j < n; j++ {
+			if b[j] < 250 {
+				b[i] = b[j] % 10
+				i++
+			}
+		}
+	}
+	return b
+}
+
+// New 生成新的验证码，返回用于显示图片和校验的 ID
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	id := hex.EncodeToString(b)
+
+	store.Lock()
+	defer store.Unlock()
+	now := time.Now()
+	if store.count++; store.count >= collectNum || len(store.entries) >= maxEntries {
+		store.count = 0
+		collect(now)
+	}
+	if len(store.entries) >= maxEntries {
+		evict()
+	}
+	store.entries[id] = &entry{
+		digits:  randomDigits(Length),
+		expires: now.Add(Expiration),
+	}
+	return id
+}
+
+// collect 清理过期的记录，调用方需要持有锁
+func collect(now time.Time) {
+	for k, e := range store.entries {
+		if now.After(e.expires) {
+			delete(store.entries, k)
+		}
+	}
+}
+
+// evict 丢弃最早过期的记录，调用方需要持有锁
+func evict() {
+	var oldest string
+	var expires time.Time
+	for k, e := range store.entries {
+		if oldest == "" || e.expires.Before(expires) {
+			oldest, expires = k, e.expires
+		}
+	}
+	delete(store.entries, oldest)
+}
+
+// Reload 为同一个 ID 重新生成数字，用于看不清时换一张
+func Reload(id string) bool {
+	store.Lock()
+	defer store.Unlock()
+	e, ok := store.entries[id]
+	if !ok || time.Now().After(e.expires) {
+		return false
+	}
+	e.digits = randomDigits(Length)
+	return true
+}
+
+// Verify 校验用户输入的数字，无论是否正确验证码都会作废
+func Verify(id, answer string) bool {
+	store.Lock()
+	e, ok := store.entries[id]
+	delete(store.entries, id)
+	store.Unlock()
+
+	if !ok || time.Now().After(e.expires) || len(answer) != len(e.digits) {
+		return false
+	}
+	for i, d := range e.digits {
+		if answer[i] != '0'+d {
+			return false
+		}
+	}
+	return true
+}
+
+// digits 返回验证码的数字，不存在时返回错误
+func digits(id string) ([]byte, error) {
+	store.Lock()
+	defer store.Unlock()
+	e, ok := store.entries[id]
+	if !ok || time.Now().After(e.expires) {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), e.digits...), nil
+}
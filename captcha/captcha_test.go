@@ -0,0 +1,123 @@
+package captcha
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+)
+
+// answer 验证码的正确答案
+func answer(t *testing.T, id string) string {
+	ds, err := digits(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, len(ds))
+	for i, d := range ds {
+		b[i] = '0' + d
+	}
+	return string(b)
+}
+
+func TestRandomDigits(t *testing.T) {
+	for _, d := range randomDigits(1000) {
+		if d > 9 {
+			t.Fatalf("数字 %d 超出范围", d)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer func(right string) string
+		want   bool
+	}{
+		{"正确", func(right string) string { return right }, true},
+		{"错误", func(right string) string { return "x" + right[1:] }, false},
+		{"过短", func(right string) string { return right[1:] }, false},
+		{"过长", func(right string) string { return right + "0" }, false},
+		{"为空", func(string) string { return "" }, false},
+	}
+	for _, tt := range tests {
+		id := New()
+		right := answer(t, id)
+		if got := Verify(id, tt.answer(right)); got != tt.want {
+			t.Errorf("%s: Verify=%v，应为 %v", tt.name, got, tt.want)
+		}
+		// 无论对错都只能校验一次
+		if Verify(id, right) {
+			t.Errorf("%s: 验证码校验后应当作废", tt.name)
+		}
+	}
+	if Verify("unknown", "12345") {
+		t.Error("不存在的验证码不应通过校验")
+	}
+}
+
+func TestExpired(t *testing.T) {
+	id := New()
+	right := answer(t, id)
+	store.Lock()
+	store.entries[id].expires = time.Now().Add(-time.Second)
+	store.Unlock()
+
+	if Reload(id) {
+		t.Error("过期的验证码不能换一张")
+	}
+	if _, err := PNG(id); err != ErrNotFound {
+		t.Errorf("过期的验证码 PNG 应返回 ErrNotFound，实际为 %v", err)
+	}
+	if Verify(id, right) {
+		t.Error("过期的验证码不应通过校验")
+	}
+}
+
+func TestReload(t *testing.T) {
+	id := New()
+	if !Reload(id) {
+		t.Fatal("Reload 应返回 true")
+	}
+	if !Verify(id, answer(t, id)) {
+		t.Error("换一张之后应使用新的数字校验")
+	}
+	if Reload(id) {
+		t.Error("已作废的验证码不能换一张")
+	}
+}
+
+func TestPNG(t *testing.T) {
+	b, err := PNG(New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != Width || size.Y != Height {
+		t.Errorf("图片大小为 %v，应为 %dx%d", size, Width, Height)
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	store.Lock()
+	store.entries = map[string]*entry{}
+	store.Unlock()
+
+	first := New()
+	for i := 0; i < maxEntries+10; i++ {
+		New()
+	}
+	store.Lock()
+	n := len(store.entries)
+	_, ok := store.entries[first]
+	store.Unlock()
+	if n > maxEntries {
+		t.Errorf("保存了 %d 个验证码，超过上限 %d", n, maxEntries)
+	}
+	if ok {
+		t.Error("超出上限时应丢弃最早过期的验证码")
+	}
+}
@@ -0,0 +1,109 @@
+package captcha
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	mrand "math/rand" // 图片只用于增加识别难度，不需要密码学安全的随机数
+)
+
+const (
+	// Width 图片宽度
+	Width = 160
+	// Height 图片高度
+	Height = 60
+
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+// font 5x7 点阵数字，每行低 5 位有效，最高位在左
+var font = [10][glyphHeight]byte{
+	{0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	{0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	{0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	{0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	{0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	{0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	{0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	{0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	{0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	{0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+}
+
+// canvas 调色板图片，0 为背景色
+type canvas struct {
+	*image.Paletted
+}
+
+// dot 画一个实心圆点
+func (c canvas) dot(x, y, r int, index uint8) {
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			if dx*dx+dy*dy <= r*r {
+				c.SetColorIndex(x+dx, y+dy, index)
+			}
+		}
+	}
+}
+
+// digit 画一个数字，点阵中的每个点画成圆点，并随机倾斜
+func (c canvas) digit(d byte, x, y, size int, skew float64, index uint8) {
+	r := size/2 + 1 // 圆点相互重叠，笔画连续
+	for row := 0; row < glyphHeight; row++ {
+		shift := int(skew * float64(glyphHeight/2-row) * float64(size))
+		for col := 0; col < glyphWidth; col++ {
+			if font[d][row]&(0x10>>uint(col)) != 0 {
+				c.dot(x+col*size+shift, y+row*size, r, index)
+			}
+		}
+	}
+}
+
+// wave 画一条贯穿图片的正弦曲线干扰线
+func (c canvas) wave(index uint8) {
+	amplitude := 4 + mrand.Float64()*8
+	period := 40 + mrand.Float64()*80
+	phase := mrand.Float64() * 2 * math.Pi
+	base := Height/4 + mrand.Intn(Height/2)
+	for x := 0; x < Width; x++ {
+		y := base + int(amplitude*math.Sin(2*math.Pi*float64(x)/period+phase))
+		c.SetColorIndex(x, y, index)
+		c.SetColorIndex(x, y+1, index)
+	}
+}
+
+// PNG 生成验证码图片
+func PNG(id string) ([]byte, error) {
+	ds, err := digits(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 白色背景加一种随机的深色
+	ink := color.RGBA{uint8(mrand.Intn(100)), uint8(mrand.Intn(100)), uint8(50 + mrand.Intn(100)), 0xFF}
+	c := canvas{image.NewPaletted(image.Rect(0, 0, Width, Height), color.Palette{color.White, ink})}
+
+	size := 5
+	step := (Width - 20) / len(ds)
+	for i, d := range ds {
+		x := 10 + i*step + mrand.Intn(step-glyphWidth*size+1)
+		y := 6 + mrand.Intn(Height-12-glyphHeight*size+1)
+		c.digit(d, x, y, size, (mrand.Float64()-0.5)*0.2, 1)
+	}
+
+	for i := 0; i < 2; i++ {
+		c.wave(1)
+	}
+	for i := 0; i < Width*Height/80; i++ {
+		c.dot(mrand.Intn(Width), mrand.Intn(Height), mrand.Intn(2), 1)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
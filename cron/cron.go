@@ -0,0 +1,235 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 计划任务的执行时间表
+type Schedule interface {
+	// Next 返回晚于 t 的下一次执行时间，没有则返回零值
+	Next(t time.Time) time.Time
+}
+
+// field 表达式中一个字段的取值范围
+type field struct {
+	name     string
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	seconds = field{"秒", 0, 59, nil}
+	minutes = field{"分", 0, 59, nil}
+	hours   = field{"时", 0, 23, nil}
+	dom     = field{"日", 1, 31, nil}
+	months  = field{"月", 1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dow = field{"周", 0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// descriptors 预定义的表达式
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// spec 标准 cron 表达式，每个字段用位图表示允许的取值
+type spec struct {
+	second, minute, hour, dom, month, dow uint64
+	// 日和周都不是 * 时，两者满足其一即可
+	anyDom, anyDow bool
+}
+
+// every 固定间隔执行
+type every struct {
+	d time.Duration
+}
+
+// Parse 解析 cron 表达式，支持 5 个字段（分 时 日 月 周）或带秒的 6 个字段，
+// 以及 @daily 等预定义表达式和 "@every 1h30m" 形式的固定间隔
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("表达式不能为空")
+	}
+	if strings.HasPrefix(expr, "@every") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, "@every")))
+		if err != nil {
+			return nil, fmt.Errorf("无效的间隔: %s", expr)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("间隔不能小于 1 秒")
+		}
+		return every{d.Truncate(time.Second)}, nil
+	}
+	if strings.HasPrefix(expr, "@") {
+		e, ok := descriptors[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf("无效的预定义表达式: %s", expr)
+		}
+		expr = e
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("表达式应包含 5 个或 6 个字段，实际为 %d 个", len(fields))
+	}
+
+	s := &spec{}
+	var err error
+	if s.second, err = parseField(fields[0], seconds); err != nil {
+		return nil, err
+	}
+	if s.minute, err = parseField(fields[1], minutes); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[2], hours); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[3], dom); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[4], months); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[5], dow); err != nil {
+		return nil, err
+	}
+	// 周日可以写成 0 或 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.anyDom = fields[3] == "*" || fields[3] == "?"
+	s.anyDow = fields[5] == "*" || fields[5] == "?"
+	return s, nil
+}
+
+// parseField 解析逗号分隔的取值列表，每一项可以是 *、n、a-b 以及带 /step 的形式
+func parseField(expr string, f field) (uint64, error) {
+	var bitmap uint64
+	for _, part := range strings.Split(expr, ",") {
+		step := uint(1)
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || n == 0 {
+				return 0, fmt.Errorf("%s字段的步长无效: %s", f.name, part)
+			}
+			step = uint(n)
+			part = part[:i]
+		}
+		lo, hi := f.min, f.max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			i := strings.Index(part, "-")
+			var err error
+			if lo, err = value(part[:i], f); err != nil {
+				return 0, err
+			}
+			if hi, err = value(part[i+1:], f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s字段的范围无效: %s", f.name, part)
+			}
+		default:
+			v, err := value(part, f)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			// "5/15" 表示从 5 开始每 15 个单位
+			if step == 1 {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bitmap |= 1 << v
+		}
+	}
+	return bitmap, nil
+}
+
+func value(s string, f field) (uint, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || uint(n) < f.min || uint(n) > f.max {
+		return 0, fmt.Errorf("%s字段的取值无效: %s，允许的范围是 %d-%d", f.name, s, f.min, f.max)
+	}
+	return uint(n), nil
+}
+
+// Next 逐级查找满足条件的月、日、时、分、秒，最多向后查找 5 年
+func (s *spec) Next(t time.Time) time.Time {
+	t = t.Add(time.Second - time.Duration(t.Nanosecond())).Truncate(time.Second)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Truncate(time.Minute).Add(time.Minute)
+			continue
+		}
+		if s.second&(1<<uint(t.Second())) == 0 {
+			t = t.Add(time.Second)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *spec) matchDay(t time.Time) bool {
+	d := s.dom&(1<<uint(t.Day())) != 0
+	w := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.anyDom || s.anyDow {
+		return d && w
+	}
+	return d || w
+}
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(e.d - time.Duration(t.Nanosecond()))
+}
+
+// NextN 返回从 t 开始的 n 次执行时间
+func NextN(s Schedule, t time.Time, n int) []time.Time {
+	times := make([]time.Time, 0, n)
+	for i := 0; i < n; i++ {
+		t = s.Next(t)
+		if t.IsZero() {
+			break
+		}
+		times = append(times, t)
+	}
+	return times
+}
@@ -0,0 +1,79 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseError(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@often",
+		"@every 10ms",
+		"@every soon",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) 应当返回错误", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	from := time.Date(2019, 5, 15, 10, 30, 20, 500, time.UTC) // 周三
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"* * * * *", []string{"2019-05-15 10:31:00", "2019-05-15 10:32:00"}},
+		{"*/15 * * * * *", []string{"2019-05-15 10:30:30", "2019-05-15 10:30:45", "2019-05-15 10:31:00"}},
+		{"0 9-17/4 * * *", []string{"2019-05-15 13:00:00", "2019-05-15 17:00:00", "2019-05-16 09:00:00"}},
+		{"5/20 * * * *", []string{"2019-05-15 10:45:00", "2019-05-15 11:05:00"}},
+		{"0 0 1,15 * *", []string{"2019-06-01 00:00:00", "2019-06-15 00:00:00"}},
+		{"0 0 * * sun", []string{"2019-05-19 00:00:00", "2019-05-26 00:00:00"}},
+		{"0 0 * * 7", []string{"2019-05-19 00:00:00"}},
+		{"0 0 * feb-mar mon", []string{"2020-02-03 00:00:00", "2020-02-10 00:00:00"}},
+		// 日和周都有限定时满足其一即可
+		{"0 0 1 * fri", []string{"2019-05-17 00:00:00", "2019-05-24 00:00:00", "2019-05-31 00:00:00", "2019-06-01 00:00:00"}},
+		{"0 0 29 2 *", []string{"2020-02-29 00:00:00", "2024-02-29 00:00:00"}},
+		{"@hourly", []string{"2019-05-15 11:00:00", "2019-05-15 12:00:00"}},
+		{"@every 1h30m", []string{"2019-05-15 12:00:20", "2019-05-15 13:30:20"}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		got := NextN(s, from, len(tt.want))
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.expr, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if s := got[i].Format("2006-01-02 15:04:05"); s != tt.want[i] {
+				t.Errorf("%q 第 %d 次: got %s, want %s", tt.expr, i+1, s, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := s.Next(time.Now()); !next.IsZero() {
+		t.Errorf("2 月 30 日不存在，got %s", next)
+	}
+}
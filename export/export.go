@@ -0,0 +1,62 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Writer 结果集导出接口，按行写入，调用方负责分批获取数据
+type Writer interface {
+	// Header 写入列名，必须在第一次调用 Row 之前调用
+	Header(columns []string) error
+	// Row 写入一行数据，nil 表示 NULL
+	Row(values []*string) error
+	// Close 写入结尾并刷新缓冲，不会关闭底层的 io.Writer
+	Close() error
+}
+
+// Format 导出格式
+type Format struct {
+	Name        string
+	Extension   string
+	ContentType string
+	new         func(w io.Writer) Writer
+}
+
+// Formats 支持的导出格式
+var Formats = map[string]*Format{
+	"csv": {
+		Name:        "CSV",
+		Extension:   "csv",
+		ContentType: "text/csv; charset=utf-8",
+		new:         func(w io.Writer) Writer { return newDelimited(w, ',') },
+	},
+	"tsv": {
+		Name:        "TSV",
+		Extension:   "tsv",
+		ContentType: "text/tab-separated-values; charset=utf-8",
+		new:         func(w io.Writer) Writer { return newDelimited(w, '\t') },
+	},
+	"jsonl": {
+		Name:        "JSON Lines",
+		Extension:   "jsonl",
+		ContentType: "application/x-ndjson; charset=utf-8",
+		new:         func(w io.Writer) Writer { return newJSONLines(w) },
+	},
+	"xlsx": {
+		Name:        "Excel",
+		Extension:   "xlsx",
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		new:         func(w io.Writer) Writer { return newXLSX(w) },
+	},
+}
+
+// New 按格式名称创建导出器
+func New(format string, w io.Writer) (Writer, *Format, error) {
+	f, ok := Formats[strings.ToLower(format)]
+	if !ok {
+		return nil, nil, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+	return f.new(w), f, nil
+}
@@ -0,0 +1,102 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// delimited CSV、TSV 导出，NULL 导出为空字段，可能被当作公式的内容加上单引号前缀
+type delimited struct {
+	w *csv.Writer
+}
+
+func newDelimited(w io.Writer, comma rune) *delimited {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	return &delimited{w: cw}
+}
+
+// escapeFormula 以 = + - @ 以及制表符、回车开头的内容在电子表格软件中会被当作公式执行，前面加上单引号，
+// 负数等能解析为数字的内容保持原样
+func escapeFormula(s string) string {
+	if s == "" || !strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s
+	}
+	return "'" + s
+}
+
+func (d *delimited) Header(columns []string) error {
+	record := make([]string, len(columns))
+	for i, name := range columns {
+		record[i] = escapeFormula(name)
+	}
+	return d.w.Write(record)
+}
+
+func (d *delimited) Row(values []*string) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		if v != nil {
+			record[i] = escapeFormula(*v)
+		}
+	}
+	if err := d.w.Write(record); err != nil {
+		return err
+	}
+	// 每行都刷新，避免 csv.Writer 在内存中积压数据
+	d.w.Flush()
+	return d.w.Error()
+}
+
+func (d *delimited) Close() error {
+	d.w.Flush()
+	return d.w.Error()
+}
+
+// jsonLines 每行一个 JSON 对象，键为列名，NULL 导出为 null
+type jsonLines struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func newJSONLines(w io.Writer) *jsonLines {
+	return &jsonLines{w: bufio.NewWriter(w)}
+}
+
+func (j *jsonLines) Header(columns []string) error {
+	j.columns = columns
+	return nil
+}
+
+func (j *jsonLines) Row(values []*string) error {
+	// 使用有序的键值对保证输出的列顺序与查询一致
+	buf := []byte{'{'}
+	for i, v := range values {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		name := ""
+		if i < len(j.columns) {
+			name = j.columns[i]
+		}
+		k, _ := json.Marshal(name)
+		buf = append(buf, k...)
+		buf = append(buf, ':')
+		val, _ := json.Marshal(v)
+		buf = append(buf, val...)
+	}
+	buf = append(buf, '}', '\n')
+	_, err := j.w.Write(buf)
+	return err
+}
+
+func (j *jsonLines) Close() error {
+	return j.w.Flush()
+}
@@ -0,0 +1,27 @@
+package export
+
+import "testing"
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"abc", "abc"},
+		{"a=b", "a=b"},
+		{"-5", "-5"},
+		{"+5", "+5"},
+		{"-1.5e3", "-1.5e3"},
+		{"=1+1", "'=1+1"},
+		{"+1+cmd|' /C calc'!A0", "'+1+cmd|' /C calc'!A0"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+	}
+	for _, tt := range tests {
+		if got := escapeFormula(tt.in); got != tt.want {
+			t.Errorf("escapeFormula(%q)=%q，应为 %q", tt.in, got, tt.want)
+		}
+	}
+}
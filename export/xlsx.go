@@ -0,0 +1,147 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+// 工作簿中除工作表以外的固定部分
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// xlsx 最简的 Office Open XML 工作簿，只有一个工作表，单元格全部使用内联字符串，
+// 工作表作为 zip 中的最后一个条目逐行写出，不需要缓存整个结果集
+type xlsx struct {
+	zw  *zip.Writer
+	w   *bufio.Writer
+	row int
+	err error
+}
+
+func newXLSX(w io.Writer) *xlsx {
+	return &xlsx{zw: zip.NewWriter(w)}
+}
+
+func (x *xlsx) begin() error {
+	for _, part := range xlsxParts {
+		f, err := x.zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+	f, err := x.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	x.w = bufio.NewWriter(f)
+	_, err = x.w.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return err
+}
+
+func (x *xlsx) write(values []*string) error {
+	if x.err != nil {
+		return x.err
+	}
+	x.row++
+	r := strconv.Itoa(x.row)
+	x.w.WriteString(`<row r="` + r + `">`)
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		x.w.WriteString(`<c r="` + cell(i) + r + `" t="inlineStr"><is><t xml:space="preserve">`)
+		xml.EscapeText(x.w, []byte(sanitize(*v)))
+		x.w.WriteString(`</t></is></c>`)
+	}
+	_, x.err = x.w.WriteString(`</row>`)
+	return x.err
+}
+
+func (x *xlsx) Header(columns []string) error {
+	if err := x.begin(); err != nil {
+		x.err = err
+		return err
+	}
+	values := make([]*string, len(columns))
+	for i := range columns {
+		values[i] = &columns[i]
+	}
+	return x.write(values)
+}
+
+func (x *xlsx) Row(values []*string) error {
+	return x.write(values)
+}
+
+func (x *xlsx) Close() error {
+	if x.err != nil {
+		return x.err
+	}
+	if x.w == nil {
+		if err := x.begin(); err != nil {
+			return err
+		}
+	}
+	if _, err := x.w.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := x.w.Flush(); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// cell 将从 0 开始的列序号转换为 A、B、...、Z、AA 形式的列名
+func cell(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sanitize 去掉 XML 1.0 不允许出现的控制字符，否则 Excel 无法打开文件
+func sanitize(s string) string {
+	clean := true
+	for _, r := range s {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return s
+	}
+	rs := make([]rune, 0, len(s))
+	for _, r := range s {
+		if r >= 0x20 || r == '\t' || r == '\n' || r == '\r' {
+			rs = append(rs, r)
+		}
+	}
+	return string(rs)
+}
@@ -1,7 +1,9 @@
 package g
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -23,10 +25,20 @@ type JwtConfig struct {
 	SecretKey string `json:"secret_key"` // "362b36fd7d514c519333234da152eaff"
 }
 
+// SessionConfig 会话 Cookie 的密钥，十六进制表示，可以用 openssl rand -hex 32 生成
+type SessionConfig struct {
+	Auth    string `json:"auth"`    // 签名密钥，至少 32 字节
+	Encrypt string `json:"encrypt"` // 加密密钥，AES-256 需要 32 字节
+	// 解码后的密钥
+	AuthKey    []byte `json:"-"`
+	EncryptKey []byte `json:"-"`
+}
+
 // SecretConfig 安全配置
 type SecretConfig struct {
-	Jwt    *JwtConfig `json:"jwt"`
-	Crypto string     `json:"crypto"`
+	Jwt     *JwtConfig     `json:"jwt"`
+	Crypto  string         `json:"crypto"`
+	Session *SessionConfig `json:"session"`
 }
 
 // DatabaseConfig 数据库配置
@@ -43,7 +55,26 @@ type MailConfig struct {
 	Addr       string `json:"addr"`
 	User       string `json:"user"`
 	Password   string `json:"password"`
-	Encryption string `json:"encryption"`
+	Encryption string `json:"encryption"` // none、starttls 或 tls，none 时只能向本机的服务器认证
+	From       string `json:"from"`       // 发件人，为空时使用 User
+	Queue      int    `json:"queue"`      // 发送队列长度
+	Retries    int    `json:"retries"`    // 发送失败后的重试次数
+	Dev        bool   `json:"dev"`        // 开发模式，邮件写入 Dir 而不真正发送
+	Dir        string `json:"dir"`
+}
+
+// QueryConfig 在线查询配置
+type QueryConfig struct {
+	MaxRows  int `json:"max_rows"`  // 单次查询返回的最大行数
+	PageSize int `json:"page_size"` // 结果集每页显示的行数
+	Timeout  int `json:"timeout"`   // 查询超时时间，单位秒
+}
+
+// AvatarConfig 头像上传配置
+type AvatarConfig struct {
+	Dir     string `json:"dir"`      // 保存目录，需要位于静态资源目录下
+	URL     string `json:"url"`      // 与 Dir 对应的访问路径
+	MaxSize int64  `json:"max_size"` // 文件大小上限，单位 KB
 }
 
 // GlobalConfig 配置
@@ -54,7 +85,11 @@ type GlobalConfig struct {
 	Database *DatabaseConfig `json:"database"`
 	Backup   *DatabaseConfig `json:"backup"`
 	Mail     *MailConfig     `json:"mail"`
+	Query    *QueryConfig    `json:"query"`
+	Avatar   *AvatarConfig   `json:"avatar"`
 	Listen   string          `json:"listen"`
+	URL      string          `json:"url"`     // 站点对外访问的地址，如 https://venus.example.com，用于邮件中的链接
+	Proxies  []string        `json:"proxies"` // 可信的反向代理，IP 或网段，只有来自这些地址的 X-Forwarded-For 才被采用
 	Secret   *SecretConfig   `json:"secret"`
 }
 
@@ -127,6 +162,76 @@ func ParseConfig(cfg string) {
 	if config.Key == "" {
 		config.Key = "key.pem"
 	}
+	if config.Query == nil {
+		config.Query = &QueryConfig{}
+	}
+	if config.Query.MaxRows <= 0 {
+		config.Query.MaxRows = 1000
+	}
+	if config.Query.PageSize <= 0 {
+		config.Query.PageSize = 50
+	}
+	if config.Query.Timeout <= 0 {
+		config.Query.Timeout = 30
+	}
+	if config.Mail == nil {
+		config.Mail = &MailConfig{}
+	}
+	if config.Mail.From == "" {
+		config.Mail.From = config.Mail.User
+	}
+	if config.Mail.Queue <= 0 {
+		config.Mail.Queue = 100
+	}
+	if config.Mail.Retries < 0 {
+		config.Mail.Retries = 0
+	}
+	if config.Mail.Dir == "" {
+		config.Mail.Dir = "mails"
+	}
+	// net/smtp 的 PLAIN 认证拒绝在未加密的连接上向本机以外的服务器发送密码，每次发送都会失败
+	if config.Mail.Enabled && config.Mail.User != "" && (config.Mail.Encryption == "" || config.Mail.Encryption == "none") {
+		host, _, _ := net.SplitHostPort(config.Mail.Addr)
+		if host != "localhost" && !net.ParseIP(host).IsLoopback() {
+			log.Fatalf("[F] 配置文件 \"%s\" 错误: 邮件服务器 %s 需要认证时必须使用 starttls 或 tls", ConfigFile, config.Mail.Addr)
+		}
+	}
+	// 邮件中的链接不能取自请求的 Host，否则可以伪造 Host 把重置密码的链接指向别的站点
+	config.URL = strings.TrimRight(strings.TrimSpace(config.URL), "/")
+	if config.URL == "" && (config.Mail.Enabled || config.Mail.Dev) {
+		log.Fatalf("[F] 配置文件 \"%s\" 错误: 启用邮件时必须配置 url", ConfigFile)
+	}
+	for _, p := range config.Proxies {
+		if _, _, err := net.ParseCIDR(p); err != nil && net.ParseIP(p) == nil {
+			log.Fatalf("[F] 配置文件 \"%s\" 错误: 无效的代理地址 %s", ConfigFile, p)
+		}
+	}
+	// 会话中保存着后端令牌，Cookie 必须签名并加密，密钥不能写死在代码里
+	if config.Secret == nil {
+		config.Secret = &SecretConfig{}
+	}
+	if config.Secret.Session == nil {
+		config.Secret.Session = &SessionConfig{}
+	}
+	session := config.Secret.Session
+	if session.AuthKey, err = hex.DecodeString(session.Auth); err != nil || len(session.AuthKey) < 32 {
+		log.Fatalf("[F] 配置文件 \"%s\" 错误: secret.session.auth 必须是至少 32 字节的十六进制密钥", ConfigFile)
+	}
+	if session.EncryptKey, err = hex.DecodeString(session.Encrypt); err != nil || len(session.EncryptKey) != 32 {
+		log.Fatalf("[F] 配置文件 \"%s\" 错误: secret.session.encrypt 必须是 32 字节的十六进制密钥", ConfigFile)
+	}
+	if config.Avatar == nil {
+		config.Avatar = &AvatarConfig{}
+	}
+	if config.Avatar.Dir == "" {
+		config.Avatar.Dir = "public/assets/images/avatars"
+	}
+	if config.Avatar.URL == "" {
+		config.Avatar.URL = "/assets/images/avatars"
+	}
+	if config.Avatar.MaxSize <= 0 {
+		config.Avatar.MaxSize = 1024
+	}
 
 	log.Debugf("[D] 读取配置文件 \"%s\" 成功。", ConfigFile)
 }
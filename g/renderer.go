@@ -10,22 +10,38 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	ttemplate "text/template"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/radovskyb/watcher"
+
+	"github.com/mia0x75/venus/sqlfmt"
 )
 
 var (
 	path      string
 	templates map[string]*template.Template
+	mails     map[string]*mailTemplate
 	renderer  *Renderer
 	lock      = new(sync.Mutex)
+	// funcs 模板中可以使用的函数
+	funcs = template.FuncMap{
+		"formatSQL":    sqlfmt.HTML,   // 格式化并高亮，带行号
+		"highlightSQL": sqlfmt.Lines,  // 保持原有排版并高亮，带行号
+		"inlineSQL":    sqlfmt.Inline, // 单行高亮，用于列表
+	}
 )
 
 // Renderer TODO
 type Renderer struct{}
 
+// mailTemplate 邮件模板，纯文本部分定义 subject，HTML 部分可以没有
+type mailTemplate struct {
+	text *ttemplate.Template
+	html *template.Template
+}
+
 func init() {
 	var err error
 	path, err = GetCurrentPath()
@@ -95,6 +111,24 @@ func flush() {
 		name := page[len(templatesDir+"views/"):]
 		templates[name] = template.Must(parse(path, name, files...))
 	}
+
+	mails = make(map[string]*mailTemplate)
+	texts, err := filepath.Glob(templatesDir + "emails/*.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, file := range texts {
+		name := strings.TrimSuffix(filepath.Base(file), ".txt")
+		mt := &mailTemplate{
+			text: ttemplate.Must(ttemplate.New(name).Funcs(ttemplate.FuncMap(funcs)).ParseFiles(file)),
+		}
+		// HTML 部分与纯文本同名，套用 emails/layout.html
+		page := templatesDir + "emails/" + name + ".html"
+		if _, err := os.Stat(page); err == nil {
+			mt.html = template.Must(parse(path, name, templatesDir+"emails/layout.html", page))
+		}
+		mails[name] = mt
+	}
 }
 
 func watch() {
@@ -164,7 +198,7 @@ func parse(path string, name string, files ...string) (*template.Template, error
 		// Not really a problem, but be consistent.
 		return nil, fmt.Errorf("html/template: no files named in call to parse")
 	}
-	tmpl := template.New(name)
+	tmpl := template.New(name).Funcs(funcs)
 	for _, file := range files {
 		b, err := ioutil.ReadFile(file)
 		if err != nil {
@@ -176,3 +210,32 @@ func parse(path string, name string, files ...string) (*template.Template, error
 	}
 	return tmpl, nil
 }
+
+// RenderMail 渲染 templates/emails 下的邮件模板，返回主题、纯文本和 HTML 正文
+func RenderMail(name string, data interface{}) (subject, text, html string, err error) {
+	lock.Lock()
+	mt, ok := mails[name]
+	lock.Unlock()
+	if !ok {
+		return "", "", "", fmt.Errorf("The mail template %s does not exist", name)
+	}
+
+	var buf strings.Builder
+	if err = mt.text.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return
+	}
+	subject = strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err = mt.text.ExecuteTemplate(&buf, name+".txt", data); err != nil {
+		return
+	}
+	text = strings.TrimSpace(buf.String()) + "\n"
+	if mt.html != nil {
+		buf.Reset()
+		if err = mt.html.ExecuteTemplate(&buf, name, data); err != nil {
+			return
+		}
+		html = buf.String()
+	}
+	return
+}
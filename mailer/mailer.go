@@ -0,0 +1,191 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mia0x75/venus/g"
+)
+
+// ErrDisabled 配置中没有启用邮件发送
+var ErrDisabled = errors.New("邮件发送未启用")
+
+// 加密方式
+const (
+	EncryptionNone     = "none"     // 明文
+	EncryptionStartTLS = "starttls" // 先明文连接，再通过 STARTTLS 升级
+	EncryptionTLS      = "tls"      // 隐式 TLS，通常使用 465 端口
+)
+
+// Message 一封邮件，HTML 不为空时同时发送纯文本和 HTML 两个版本
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// seq 开发模式下生成文件名用的序号
+var seq uint64
+
+// bytes 生成邮件内容，正文使用 base64 编码以支持中文
+func (m *Message) bytes(from string) []byte {
+	var buf bytes.Buffer
+	header := func(k, v string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+	}
+	header("From", from)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		header("Content-Type", "text/plain; charset=UTF-8")
+		header("Content-Transfer-Encoding", "base64")
+		buf.WriteString("\r\n")
+		writeBase64(&buf, m.Text)
+		return buf.Bytes()
+	}
+
+	w := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/alternative; boundary="+w.Boundary())
+	buf.WriteString("\r\n")
+	// 客户端优先显示最后一个能识别的部分，所以 HTML 放在后面
+	for _, part := range []struct{ typ, body string }{
+		{"text/plain", m.Text},
+		{"text/html", m.HTML},
+	} {
+		pw, _ := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.typ + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		var body bytes.Buffer
+		writeBase64(&body, part.body)
+		pw.Write(body.Bytes())
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+// writeBase64 按每行 76 个字符写入 base64 编码后的内容
+func writeBase64(buf *bytes.Buffer, s string) {
+	body := base64.StdEncoding.EncodeToString([]byte(s))
+	for len(body) > 76 {
+		buf.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	buf.WriteString(body + "\r\n")
+}
+
+// dial 按配置的加密方式连接 SMTP 服务器
+func dial(cfg *g.MailConfig) (*smtp.Client, error) {
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{ServerName: host}
+
+	var c *smtp.Client
+	switch cfg.Encryption {
+	case EncryptionTLS:
+		conn, err := tls.Dial("tcp", cfg.Addr, tc)
+		if err != nil {
+			return nil, err
+		}
+		if c, err = smtp.NewClient(conn, host); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	case EncryptionStartTLS, EncryptionNone, "":
+		if c, err = smtp.Dial(cfg.Addr); err != nil {
+			return nil, err
+		}
+		if cfg.Encryption == EncryptionStartTLS {
+			if err = c.StartTLS(tc); err != nil {
+				c.Close()
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("不支持的加密方式: %s", cfg.Encryption)
+	}
+
+	// 未加密时 net/smtp 只允许向本机的服务器认证，读取配置时已经检查过
+	if cfg.User != "" {
+		if err = c.Auth(smtp.PlainAuth("", cfg.User, cfg.Password, host)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Send 通过配置的 SMTP 服务器发送邮件，开发模式下只写入文件，不需要启用 SMTP
+func Send(m *Message) error {
+	cfg := g.Config().Mail
+	if cfg.Dev {
+		return save(cfg, m)
+	}
+	if !cfg.Enabled {
+		return ErrDisabled
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return fmt.Errorf("无效的发件人地址: %v", err)
+	}
+
+	c, err := dial(cfg)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err = c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range m.To {
+		if err = c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(m.bytes(from.String())); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// save 开发模式下把邮件保存为 .eml 文件，可以直接用邮件客户端打开检查
+func save(cfg *g.MailConfig, m *Message) error {
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return err
+	}
+	from := cfg.From
+	if from == "" {
+		from = "venus@localhost"
+	}
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().Format("20060102-150405"), atomic.AddUint64(&seq, 1))
+	return ioutil.WriteFile(filepath.Join(cfg.Dir, name), m.bytes(from), 0644)
+}
@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/mia0x75/venus/g"
+)
+
+// ErrQueueFull 发送队列已满
+var ErrQueueFull = errors.New("邮件发送队列已满")
+
+var (
+	queue chan *Message
+	wg    sync.WaitGroup
+)
+
+// Start 启动后台发送队列，需要在解析配置之后调用
+func Start() {
+	cfg := g.Config().Mail
+	queue = make(chan *Message, cfg.Queue)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for m := range queue {
+			deliver(m, cfg.Retries)
+		}
+	}()
+}
+
+// Stop 停止接收新邮件，等待队列中的邮件发送完毕或者超时
+func Stop(ctx context.Context) error {
+	if queue == nil {
+		return nil
+	}
+	close(queue)
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Enqueue 把邮件放入发送队列，不等待发送结果，队列未启动时直接发送
+func Enqueue(m *Message) error {
+	cfg := g.Config().Mail
+	if !cfg.Enabled && !cfg.Dev {
+		return ErrDisabled
+	}
+	if queue == nil {
+		return Send(m)
+	}
+	select {
+	case queue <- m:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// deliver 发送一封邮件，失败后按 1s、2s、4s ... 的间隔重试
+func deliver(m *Message, retries int) {
+	delay := time.Second
+	for i := 0; ; i++ {
+		err := Send(m)
+		if err == nil {
+			return
+		}
+		if err == ErrDisabled || i >= retries {
+			log.Errorf("[E] 邮件发送失败，主题: %s，收件人: %v，错误信息: %s", m.Subject, m.To, err.Error())
+			return
+		}
+		log.Warnf("[W] 邮件发送失败，%s 后重试，主题: %s，收件人: %v，错误信息: %s", delay, m.Subject, m.To, err.Error())
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,19 @@
+package mailer
+
+import (
+	"github.com/mia0x75/venus/g"
+)
+
+// Render 使用 templates/emails 下的模板生成邮件，主题在纯文本模板的 subject 中定义
+func Render(name string, data interface{}, to ...string) (*Message, error) {
+	subject, text, html, err := g.RenderMail(name, data)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		To:      to,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	}, nil
+}
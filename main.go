@@ -18,6 +18,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/mia0x75/venus/g"
+	"github.com/mia0x75/venus/mailer"
 	"github.com/mia0x75/venus/routes"
 )
 
@@ -54,6 +55,7 @@ func main() {
 
 	g.ParseConfig(*cfg)
 	g.InitLog()
+	mailer.Start()
 
 	e := echo.New()
 	e.HideBanner = true
@@ -105,7 +107,8 @@ func main() {
 		AllowMethods: []string{echo.GET, echo.PUT, echo.POST, echo.DELETE},
 	}))
 
-	e.Use(session.Middleware(sessions.NewCookieStore([]byte("secret-key"))))
+	secret := g.Config().Secret.Session
+	e.Use(session.Middleware(sessions.NewCookieStore(secret.AuthKey, secret.EncryptKey)))
 
 	routes.Routes(e)
 
@@ -135,4 +138,8 @@ func main() {
 	if err := e.Shutdown(ctx); err != nil {
 		e.Logger.Fatal(err)
 	}
+	// 尽量把队列中的邮件发完再退出
+	if err := mailer.Stop(ctx); err != nil {
+		log.Warnf("[W] 邮件队列未发送完毕: %s", err.Error())
+	}
 }
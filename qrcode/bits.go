@@ -0,0 +1,24 @@
+package qrcode
+
+// bitBuffer 按位追加的缓冲区，高位在前
+type bitBuffer struct {
+	bytes []byte
+	n     int
+}
+
+func (b *bitBuffer) len() int {
+	return b.n
+}
+
+// append 追加 v 的低 length 位
+func (b *bitBuffer) append(v uint, length int) {
+	for i := length - 1; i >= 0; i-- {
+		if b.n%8 == 0 {
+			b.bytes = append(b.bytes, 0)
+		}
+		if v>>uint(i)&1 == 1 {
+			b.bytes[b.n/8] |= 0x80 >> uint(b.n%8)
+		}
+		b.n++
+	}
+}
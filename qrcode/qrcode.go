@@ -0,0 +1,423 @@
+// Package qrcode 生成二维码，只支持字节模式和 M 级纠错，版本 1 到 10，
+// 最多可以容纳 213 个字节，用于两步验证的 otpauth 链接已经足够
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// ErrTooLong 内容超出版本 10 的容量
+var ErrTooLong = errors.New("二维码内容过长")
+
+// block M 级纠错下每个版本的分块方式
+type block struct {
+	ec       int // 每块的纠错码字数
+	g1, d1   int // 第一组的块数和每块的数据码字数
+	g2, d2   int // 第二组的块数和每块的数据码字数
+	position []int
+}
+
+var versions = [...]block{
+	1:  {10, 1, 16, 0, 0, nil},
+	2:  {16, 1, 28, 0, 0, []int{6, 18}},
+	3:  {26, 1, 44, 0, 0, []int{6, 22}},
+	4:  {18, 2, 32, 0, 0, []int{6, 26}},
+	5:  {24, 2, 43, 0, 0, []int{6, 30}},
+	6:  {16, 4, 27, 0, 0, []int{6, 34}},
+	7:  {18, 4, 31, 0, 0, []int{6, 22, 38}},
+	8:  {22, 2, 38, 2, 39, []int{6, 24, 42}},
+	9:  {22, 3, 36, 2, 37, []int{6, 26, 46}},
+	10: {26, 4, 43, 1, 44, []int{6, 28, 50}},
+}
+
+// capacity 数据码字总数
+func (b block) capacity() int {
+	return b.g1*b.d1 + b.g2*b.d2
+}
+
+// Code 二维码矩阵，不含四周的空白
+type Code struct {
+	Size     int
+	modules  [][]bool
+	reserved [][]bool // 功能图形占用的位置，不放数据也不加掩码
+}
+
+// Black 第 y 行第 x 列是否为深色
+func (q *Code) Black(x, y int) bool {
+	return q.modules[y][x]
+}
+
+// Encode 生成二维码，自动选择能容纳内容的最小版本和惩罚分最低的掩码
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	version := 0
+	for v := 1; v < len(versions); v++ {
+		// 模式指示 4 位，字符数 8 位或 16 位
+		header := 12
+		if v >= 10 {
+			header = 20
+		}
+		if (header+len(data)*8+7)/8 <= versions[v].capacity() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	codewords := interleave(versions[version], encodeData(data, version))
+
+	q := &Code{Size: version*4 + 17}
+	q.modules = make([][]bool, q.Size)
+	q.reserved = make([][]bool, q.Size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, q.Size)
+		q.reserved[i] = make([]bool, q.Size)
+	}
+	q.drawFunctionPatterns(version)
+	q.drawCodewords(codewords)
+
+	best, lowest := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if p := q.penalty(); lowest < 0 || p < lowest {
+			best, lowest = mask, p
+		}
+		q.applyMask(mask) // 掩码是异或，再做一次即可还原
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return q, nil
+}
+
+// encodeData 按字节模式编码，补齐到当前版本的数据码字数
+func encodeData(data []byte, version int) []byte {
+	var bits bitBuffer
+	bits.append(0x4, 4)
+	if version >= 10 {
+		bits.append(uint(len(data)), 16)
+	} else {
+		bits.append(uint(len(data)), 8)
+	}
+	for _, b := range data {
+		bits.append(uint(b), 8)
+	}
+
+	capacity := versions[version].capacity() * 8
+	terminator := capacity - bits.len()
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-bits.len()%8)%8)
+	for pad := uint(0xEC); bits.len() < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	return bits.bytes
+}
+
+// interleave 分块计算纠错码，然后按列交错排列数据码字和纠错码字
+func interleave(b block, data []byte) []byte {
+	var blocks, ecs [][]byte
+	generator := rsGenerator(b.ec)
+	for i := 0; i < b.g1+b.g2; i++ {
+		n := b.d1
+		if i >= b.g1 {
+			n = b.d2
+		}
+		blocks = append(blocks, data[:n])
+		ecs = append(ecs, rsRemainder(data[:n], generator))
+		data = data[n:]
+	}
+
+	var result []byte
+	for i := 0; i < b.d1 || i < b.d2; i++ {
+		for _, d := range blocks {
+			if i < len(d) {
+				result = append(result, d[i])
+			}
+		}
+	}
+	for i := 0; i < b.ec; i++ {
+		for _, e := range ecs {
+			result = append(result, e[i])
+		}
+	}
+	return result
+}
+
+// set 设置功能图形
+func (q *Code) set(x, y int, black bool) {
+	q.modules[y][x] = black
+	q.reserved[y][x] = true
+}
+
+// drawFunctionPatterns 画定位图形、时序图形、校正图形，并为格式信息和版本信息预留位置
+func (q *Code) drawFunctionPatterns(version int) {
+	for i := 0; i < q.Size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+
+	// 定位图形连同分隔符
+	for _, c := range [][2]int{{3, 3}, {q.Size - 4, 3}, {3, q.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || x >= q.Size || y < 0 || y >= q.Size {
+					continue
+				}
+				d := max(abs(dx), abs(dy))
+				q.set(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	// 校正图形，与定位图形重叠的三个位置跳过
+	position := versions[version].position
+	last := len(position) - 1
+	for i, cy := range position {
+		for j, cx := range position {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	q.drawFormat(0)
+
+	if version >= 7 {
+		bits := version<<12 | bch(version, 0x1F25)
+		for i := 0; i < 18; i++ {
+			black := bits>>uint(i)&1 == 1
+			a, b := q.Size-11+i%3, i/3
+			q.set(a, b, black)
+			q.set(b, a, black)
+		}
+	}
+}
+
+// drawFormat 写入两份格式信息，M 级纠错的指示位为 00
+func (q *Code) drawFormat(mask int) {
+	bits := (mask<<10 | bch(mask, 0x537)) ^ 0x5412
+	bit := func(i int) bool {
+		return bits>>uint(i)&1 == 1
+	}
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.set(q.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.Size-15+i, bit(i))
+	}
+	q.set(8, q.Size-8, true) // 固定的深色模块
+}
+
+// drawCodewords 从右下角开始，每两列一组上下往返放置数据，跳过第 6 列的时序图形
+func (q *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if upward {
+					y = q.Size - 1 - vert
+				}
+				if q.reserved[y][x] {
+					continue
+				}
+				// 剩余位保持为浅色
+				if i < len(codewords)*8 {
+					q.modules[y][x] = codewords[i/8]>>uint(7-i%8)&1 == 1
+				}
+				i++
+			}
+		}
+	}
+}
+
+// applyMask 对数据区域应用掩码
+func (q *Code) applyMask(mask int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if q.reserved[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (y/2+x/3)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty 按标准中的四条规则计算惩罚分，分数越低越容易识别
+func (q *Code) penalty() int {
+	n := q.Size
+	score, dark := 0, 0
+	finder := []bool{true, false, true, true, true, false, true}
+
+	for _, vertical := range []bool{false, true} {
+		at := func(a, b int) bool {
+			if vertical {
+				return q.modules[b][a]
+			}
+			return q.modules[a][b]
+		}
+		// [from, to) 范围内是否全为浅色，超出边界视为浅色
+		light := func(a, from, to int) bool {
+			for b := from; b < to; b++ {
+				if b >= 0 && b < n && at(a, b) {
+					return false
+				}
+			}
+			return true
+		}
+		for a := 0; a < n; a++ {
+			run := 1
+			for b := 1; b < n; b++ {
+				if at(a, b) == at(a, b-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				score += run - 2
+			}
+
+			// 类似定位图形的 1:1:3:1:1，且一侧有 4 个浅色模块
+			for b := 0; b+7 <= n; b++ {
+				match := true
+				for k, v := range finder {
+					if at(a, b+k) != v {
+						match = false
+						break
+					}
+				}
+				if match && (light(a, b-4, b) || light(a, b+7, b+11)) {
+					score += 40
+				}
+			}
+		}
+	}
+
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			c := q.modules[y][x]
+			if c {
+				dark++
+			}
+			if x+1 < n && y+1 < n && c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+				score += 3
+			}
+		}
+	}
+
+	percent := dark * 100 / (n * n)
+	score += abs(percent-50) / 5 * 10
+	return score
+}
+
+// PNG 生成图片，scale 为每个模块的像素数，四周留出 4 个模块的空白
+func (q *Code) PNG(scale int) ([]byte, error) {
+	const quiet = 4
+	size := (q.Size + quiet*2) * scale
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if !q.modules[y][x] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+quiet)*scale+dx, (y+quiet)*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bch 计算格式信息和版本信息的 BCH 校验位，即 data 左移后除以 poly 的余数
+func bch(data, poly int) int {
+	degree := bitLen(poly) - 1
+	rem := data << uint(degree)
+	for bitLen(rem) > degree {
+		rem ^= poly << uint(bitLen(rem)-1-degree)
+	}
+	return rem
+}
+
+func bitLen(n int) int {
+	l := 0
+	for ; n > 0; n >>= 1 {
+		l++
+	}
+	return l
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,110 @@
+package qrcode
+
+import (
+	"bytes"
+	"testing"
+)
+
+// 版本 1 的 "HELLO"，由另外实现的解码程序核对过格式信息、数据和纠错码
+var hello = []string{
+	"#######.#..#..#######",
+	"#.....#.####..#.....#",
+	"#.###.#...#.#.#.###.#",
+	"#.###.#.#.#.#.#.###.#",
+	"#.###.#....#..#.###.#",
+	"#.....#....##.#.....#",
+	"#######.#.#.#.#######",
+	"........#..##........",
+	"#.##.###.#.##.#..#.##",
+	".##.##.#.######..##..",
+	"#...#.#..#.#.......##",
+	"#.##...#...#..####.#.",
+	".#.######...#..#..#.#",
+	"........####..#...#.#",
+	"#######.#..##..#.....",
+	"#.....#.#.#....#####.",
+	"#.###.#.....######.##",
+	"#.###.#.#.##..#.####.",
+	"#.###.#.##..#.##..#..",
+	"#.....#...#..#.##...#",
+	"#######.#.#..#.#.....",
+}
+
+func TestEncodeGolden(t *testing.T) {
+	q, err := Encode("HELLO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Size != len(hello) {
+		t.Fatalf("Size=%d，应为 %d", q.Size, len(hello))
+	}
+	for y, row := range hello {
+		for x, c := range row {
+			if q.Black(x, y) != (c == '#') {
+				t.Errorf("(%d, %d) 与预期不符", x, y)
+			}
+		}
+	}
+}
+
+func TestEncodeVersion(t *testing.T) {
+	tests := []struct {
+		n    int // 字节数
+		size int
+	}{
+		{1, 21},
+		{14, 21},
+		{15, 25},
+		{26, 25},
+		{27, 29},
+		{152, 49},
+		{153, 53},
+		{180, 53},
+		{181, 57},
+		{213, 57},
+	}
+	for _, tt := range tests {
+		q, err := Encode(string(bytes.Repeat([]byte("a"), tt.n)))
+		if err != nil {
+			t.Errorf("%d 字节: %v", tt.n, err)
+			continue
+		}
+		if q.Size != tt.size {
+			t.Errorf("%d 字节: Size=%d，应为 %d", tt.n, q.Size, tt.size)
+		}
+	}
+	if _, err := Encode(string(bytes.Repeat([]byte("a"), 214))); err != ErrTooLong {
+		t.Errorf("214 字节应返回 ErrTooLong，实际为 %v", err)
+	}
+}
+
+// 标准附录中版本 1-M 的 "HELLO WORLD" 数据码字和纠错码字
+func TestReedSolomon(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	got := rsRemainder(data, rsGenerator(len(want)))
+	if !bytes.Equal(got, want) {
+		t.Errorf("纠错码字为 %v，应为 %v", got, want)
+	}
+}
+
+func TestBCH(t *testing.T) {
+	tests := []struct {
+		data, poly, mask int
+		want             int
+	}{
+		// M 级纠错的格式信息，纠错等级 00 加掩码编号
+		{0, 0x537, 0x5412, 0x5412},
+		{3, 0x537, 0x5412, 0x5B4B},
+		{7, 0x537, 0x5412, 0x4AA0},
+		// 版本信息
+		{7, 0x1F25, 0, 0x07C94},
+		{8, 0x1F25, 0, 0x085BC},
+		{10, 0x1F25, 0, 0x0A4D3},
+	}
+	for _, tt := range tests {
+		if got := (tt.data<<uint(bitLen(tt.poly)-1) | bch(tt.data, tt.poly)) ^ tt.mask; got != tt.want {
+			t.Errorf("bch(%d, %#x)=%#x，应为 %#x", tt.data, tt.poly, got, tt.want)
+		}
+	}
+}
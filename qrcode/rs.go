@@ -0,0 +1,60 @@
+package qrcode
+
+// GF(256) 上的运算，本原多项式为 x^8 + x^4 + x^3 + x^2 + 1
+var exp, log [256]int
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		log[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	exp[255] = exp[0]
+}
+
+func mul(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return exp[(log[a]+log[b])%255]
+}
+
+// rsGenerator 生成 n 个纠错码字的生成多项式 (x - α^0)(x - α^1)...(x - α^(n-1))，
+// 系数从高次到低次排列，省略最高次项的系数 1
+func rsGenerator(n int) []int {
+	g := make([]int, n)
+	g[n-1] = 1
+	root := 1
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			g[j] = mul(g[j], root)
+			if j+1 < n {
+				g[j] ^= g[j+1]
+			}
+		}
+		root = mul(root, 2)
+	}
+	return g
+}
+
+// rsRemainder 数据多项式除以生成多项式的余数，即纠错码字
+func rsRemainder(data []byte, g []int) []byte {
+	rem := make([]int, len(g))
+	for _, b := range data {
+		factor := int(b) ^ rem[0]
+		copy(rem, rem[1:])
+		rem[len(rem)-1] = 0
+		for i := range rem {
+			rem[i] ^= mul(g[i], factor)
+		}
+	}
+	result := make([]byte, len(rem))
+	for i, r := range rem {
+		result[i] = byte(r)
+	}
+	return result
+}
@@ -0,0 +1,526 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+
+	"github.com/mia0x75/venus/captcha"
+	"github.com/mia0x75/venus/g"
+	"github.com/mia0x75/venus/mailer"
+)
+
+// resetTTL 重置密码链接的有效期
+const resetTTL = 30 * time.Minute
+
+var (
+	// 重置密码邮件的发送频率限制，避免被用来骚扰他人的邮箱
+	resetByEmail = newLimiter(3, time.Hour)
+	resetByIP    = newLimiter(10, time.Hour)
+	// 重新发送验证邮件的频率限制，理由同上
+	resendByEmail = newLimiter(3, time.Hour)
+	resendByIP    = newLimiter(10, time.Hour)
+)
+
+// optionTTL 公开选项的缓存时间，登录页等每次请求都要读取，不必每次都查询后端
+const optionTTL = time.Minute
+
+// publicOptions 公开选项的缓存，在本进程中修改系统选项后会清空
+var publicOptions = struct {
+	sync.Mutex
+	values map[string]string
+	expire map[string]time.Time
+}{
+	values: map[string]string{},
+	expire: map[string]time.Time{},
+}
+
+// publicOption 读取无需登录即可访问的系统选项，读取失败时不缓存
+func publicOption(name string) (string, error) {
+	publicOptions.Lock()
+	v, ok := publicOptions.values[name]
+	if ok && time.Now().Before(publicOptions.expire[name]) {
+		publicOptions.Unlock()
+		return v, nil
+	}
+	publicOptions.Unlock()
+
+	v, err := fetchPublicOption(name)
+	if err != nil {
+		return "", err
+	}
+	publicOptions.Lock()
+	publicOptions.values[name] = v
+	publicOptions.expire[name] = time.Now().Add(optionTTL)
+	publicOptions.Unlock()
+	return v, nil
+}
+
+// forgetPublicOptions 清空公开选项的缓存
+func forgetPublicOptions() {
+	publicOptions.Lock()
+	defer publicOptions.Unlock()
+	publicOptions.values = map[string]string{}
+	publicOptions.expire = map[string]time.Time{}
+}
+
+// fetchPublicOption 从后端读取公开选项
+func fetchPublicOption(name string) (string, error) {
+	req := graphql.NewRequest(`query ($name: String!) {
+  option (Name: $name) {
+    Value
+  }
+}`)
+	req.Var("name", name)
+
+	// set header fields
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Option struct {
+			Value string
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		return "", err
+	}
+	return resp.Option.Value, nil
+}
+
+// registrationOpen 是否开放自助注册，由系统选项 registration 控制，管理员可以在系统选项中关闭
+func registrationOpen() bool {
+	v, err := publicOption("registration")
+	if err != nil {
+		log.Println(err)
+	}
+	return v == "true"
+}
+
+// absoluteURL 生成邮件中使用的完整链接，站点地址取自配置而不是请求的 Host
+func absoluteURL(path string) string {
+	return g.Config().URL + path
+}
+
+// sendMail 使用 templates/emails 下的模板生成邮件并放入发送队列
+func sendMail(name string, data interface{}, to string) error {
+	m, err := mailer.Render(name, data, to)
+	if err != nil {
+		return err
+	}
+	return mailer.Enqueue(m)
+}
+
+// sendVerification 发送邮箱验证邮件
+func sendVerification(name, email, token string) error {
+	return sendMail("verify-email", map[string]interface{}{
+		"Name": name,
+		"Link": absoluteURL("/verify-email.html?token=" + url.QueryEscape(token)),
+	}, email)
+}
+
+func login(c echo.Context) error {
+	email := strings.TrimSpace(c.FormValue("email"))
+	account, ip := strings.ToLower(email), clientIP(c)
+	data := map[string]interface{}{
+		"email": email,
+		"open":  registrationOpen(),
+	}
+	render := func() error {
+		if needCaptcha(loginByAccount.count(account), loginByIP.count(ip)) {
+			data["captcha"] = captcha.New()
+		}
+		return c.Render(http.StatusOK, "login.html", data)
+	}
+	if c.Request().Method != http.MethodPost {
+		return render()
+	}
+
+	// 锁定期间不再向后端验证密码，即使密码正确也不能登录
+	locked, wait := loginByIP.locked(ip)
+	if !locked {
+		locked, wait = loginByAccount.locked(account)
+	}
+	if locked {
+		data["message"] = lockMessage(wait)
+		return render()
+	}
+	if needCaptcha(loginByAccount.count(account), loginByIP.count(ip)) {
+		if message := checkCaptcha(c); message != "" {
+			data["message"] = message
+			return render()
+		}
+	}
+
+	req := graphql.NewRequest(`mutation ($email: String! $password: String!) {
+  login (
+    input: {
+      Email: $email
+      Password: $password
+    }
+  ) {
+    Me {
+      UUID
+      Name
+      Roles
+      Verified
+      TwoFactor
+    }
+    Token
+  }
+}`)
+	req.Var("email", email)
+	req.Var("password", c.FormValue("password"))
+
+	// set header fields
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Login struct {
+			Me struct {
+				UUID      string
+				Name      string
+				Roles     []string
+				Verified  bool
+				TwoFactor bool
+			}
+			Token string
+		}
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		loginByAccount.hit(account)
+		loginByIP.hit(ip)
+		data["message"] = "邮箱或密码错误"
+		return render()
+	}
+	// 邮箱验证之前不允许登录
+	if !resp.Login.Me.Verified {
+		data["unverified"] = true
+		return render()
+	}
+
+	sess, _ := session.Get("session", c)
+	delete(sess.Values, "token")
+	delete(sess.Values, "enrolling")
+	delete(sess.Values, "enrolling_at")
+	// 启用了两步验证时，令牌先暂存，输入验证码后才换成正式的会话，失败次数在第二步通过后才清零
+	if resp.Login.Me.TwoFactor {
+		sess.Values["pending"] = resp.Login.Token
+		sess.Values["pending_at"] = time.Now().Unix()
+		sess.Values["pending_account"] = account
+		sess.Values["pending_uuid"] = resp.Login.Me.UUID
+		sess.Save(c.Request(), c.Response())
+		return c.Redirect(http.StatusFound, "/two-factor.html")
+	}
+	loginByAccount.reset(account)
+	// 角色要求启用两步验证但还没有启用时，令牌同样暂存，只能用于完成设置
+	if twoFactorRequired(resp.Login.Me.Roles) {
+		sess.Values["enrolling"] = resp.Login.Token
+		sess.Values["enrolling_at"] = time.Now().Unix()
+		sess.Save(c.Request(), c.Response())
+		return c.Redirect(http.StatusFound, "/profile.html#two-factor")
+	}
+	sess.Values["token"] = resp.Login.Token
+	sess.Save(c.Request(), c.Response())
+	return c.Redirect(http.StatusFound, "/index.html")
+}
+
+// register 自助注册，注册后需要先验证邮箱才能登录
+func register(c echo.Context) error {
+	form := map[string]string{
+		"name":  strings.TrimSpace(c.FormValue("name")),
+		"email": strings.TrimSpace(c.FormValue("email")),
+		"agree": c.FormValue("agree"),
+	}
+	open := registrationOpen()
+	ip := clientIP(c)
+	data := map[string]interface{}{
+		"form": form,
+		"open": open,
+	}
+	render := func() error {
+		if open && needCaptcha(registerByIP.count(ip)) {
+			data["captcha"] = captcha.New()
+		}
+		return c.Render(http.StatusOK, "register.html", data)
+	}
+	if c.Request().Method != http.MethodPost {
+		return render()
+	}
+	if !open {
+		return echo.NewHTTPError(http.StatusForbidden)
+	}
+	if needCaptcha(registerByIP.count(ip)) {
+		if message := checkCaptcha(c); message != "" {
+			data["message"] = message
+			return render()
+		}
+	}
+	registerByIP.hit(ip)
+
+	errs := map[string]string{}
+	if form["name"] == "" {
+		errs["name"] = "请填写姓名"
+	}
+	// 只使用解析出的地址，"名字 <地址>" 之类的写法不能原样交给后端和 SMTP
+	if addr, err := mail.ParseAddress(form["email"]); err != nil {
+		errs["email"] = "无效的邮箱地址"
+	} else {
+		form["email"] = addr.Address
+	}
+	if len(c.FormValue("password")) < 8 {
+		errs["password"] = "密码至少需要 8 个字符"
+	}
+	if form["agree"] != "1" {
+		errs["agree"] = "请阅读并同意许可协议"
+	}
+	data["errors"] = errs
+	if len(errs) > 0 {
+		return render()
+	}
+
+	req := graphql.NewRequest(`mutation ($input: RegisterInput!) {
+  register(input: $input) {
+    Token
+  }
+}`)
+	req.Var("input", map[string]interface{}{
+		"Name":     form["name"],
+		"Email":    form["email"],
+		"Password": c.FormValue("password"),
+	})
+
+	// set header fields
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Register struct {
+			Token string // 邮箱验证令牌
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		data["message"] = err.Error()
+		return render()
+	}
+
+	if err := sendVerification(form["name"], form["email"], resp.Register.Token); err != nil {
+		log.Println(err)
+		data["message"] = "账号已创建，但验证邮件发送失败，请稍后在登录页重新发送，或联系管理员"
+	} else {
+		data["sent"] = form["email"]
+	}
+	return render()
+}
+
+// verifyEmail 打开邮件中的链接时验证邮箱，提交邮箱地址时重新发送验证邮件
+func verifyEmail(c echo.Context) error {
+	email := strings.TrimSpace(c.FormValue("email"))
+	ip := clientIP(c)
+	data := map[string]interface{}{
+		"email": email,
+	}
+	render := func() error {
+		if needCaptcha(resendByIP.count(ip)) {
+			data["captcha"] = captcha.New()
+		}
+		return c.Render(http.StatusOK, "verify-email.html", data)
+	}
+
+	if token := c.QueryParam("token"); token != "" {
+		req := graphql.NewRequest(`mutation ($token: String!) {
+  verifyEmail(Token: $token) {
+    UUID
+  }
+}`)
+		req.Var("token", token)
+
+		// set header fields
+		req.Header.Set("Cache-Control", "no-cache")
+
+		var resp struct{}
+		if err := request(req, &resp); err != nil {
+			log.Println(err)
+			data["message"] = "验证链接无效或已过期，请重新发送验证邮件"
+		} else {
+			data["verified"] = true
+		}
+		return render()
+	}
+
+	if c.Request().Method != http.MethodPost {
+		return render()
+	}
+	if needCaptcha(resendByIP.count(ip)) {
+		if message := checkCaptcha(c); message != "" {
+			data["message"] = message
+			return render()
+		}
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		data["message"] = "无效的邮箱地址"
+		return render()
+	}
+	email = addr.Address
+	data["email"] = email
+	if !resendByIP.allow(ip) || !resendByEmail.allow(strings.ToLower(email)) {
+		data["message"] = "请求过于频繁，请稍后再试"
+		return render()
+	}
+
+	req := graphql.NewRequest(`mutation ($email: String!) {
+  resendVerification(Email: $email) {
+    Name
+    Token
+  }
+}`)
+	req.Var("email", email)
+
+	// set header fields
+	req.Header.Set("Cache-Control", "no-cache")
+
+	// 邮箱不存在或已经验证时后端返回空令牌，页面提示相同，避免泄露邮箱是否注册
+	var resp struct {
+		ResendVerification struct {
+			Name  string
+			Token string
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	} else if r := resp.ResendVerification; r.Token != "" {
+		if err := sendVerification(r.Name, email, r.Token); err != nil {
+			log.Println(err)
+		}
+	}
+	data["resent"] = true
+	return render()
+}
+
+// forgot 申请重置密码，无论邮箱是否注册都显示相同的提示
+func forgot(c echo.Context) error {
+	email := strings.TrimSpace(c.FormValue("email"))
+	ip := clientIP(c)
+	data := map[string]interface{}{
+		"email": email,
+	}
+	render := func() error {
+		if needCaptcha(resetByIP.count(ip)) {
+			data["captcha"] = captcha.New()
+		}
+		return c.Render(http.StatusOK, "forgot-password.html", data)
+	}
+	if c.Request().Method != http.MethodPost {
+		return render()
+	}
+	if needCaptcha(resetByIP.count(ip)) {
+		if message := checkCaptcha(c); message != "" {
+			data["message"] = message
+			return render()
+		}
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil {
+		data["message"] = "无效的邮箱地址"
+		return render()
+	}
+	email = addr.Address
+	data["email"] = email
+	if !resetByIP.allow(ip) || !resetByEmail.allow(strings.ToLower(email)) {
+		data["message"] = "请求过于频繁，请稍后再试"
+		return render()
+	}
+
+	req := graphql.NewRequest(`mutation ($email: String! $minutes: Int!) {
+  requestPasswordReset(Email: $email, Minutes: $minutes) {
+    Name
+    Token
+  }
+}`)
+	req.Var("email", email)
+	req.Var("minutes", int(resetTTL/time.Minute))
+
+	// set header fields
+	req.Header.Set("Cache-Control", "no-cache")
+
+	// 邮箱不存在时后端返回空令牌
+	var resp struct {
+		RequestPasswordReset struct {
+			Name  string
+			Token string
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	} else if r := resp.RequestPasswordReset; r.Token != "" {
+		err := sendMail("reset-password", map[string]interface{}{
+			"Name":    r.Name,
+			"Link":    absoluteURL("/reset-password.html?token=" + url.QueryEscape(r.Token)),
+			"Minutes": int(resetTTL / time.Minute),
+		}, email)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	data["sent"] = true
+	return render()
+}
+
+// recoverPassword 通过邮件中的链接设置新密码，令牌是否过期、是否已经使用由后端校验
+func recoverPassword(c echo.Context) error {
+	token := c.FormValue("token")
+	data := map[string]interface{}{
+		"token": token,
+	}
+	if token == "" {
+		return c.Redirect(http.StatusFound, "/forgot-password.html")
+	}
+	if c.Request().Method != http.MethodPost {
+		return c.Render(http.StatusOK, "reset-password.html", data)
+	}
+
+	errs := map[string]string{}
+	password := c.FormValue("password")
+	if len(password) < 8 {
+		errs["password"] = "密码至少需要 8 个字符"
+	} else if password != c.FormValue("confirm") {
+		errs["confirm"] = "两次输入的密码不一致"
+	}
+	data["errors"] = errs
+	if len(errs) > 0 {
+		return c.Render(http.StatusOK, "reset-password.html", data)
+	}
+
+	req := graphql.NewRequest(`mutation ($token: String! $password: String!) {
+  recoverPassword(Token: $token, Password: $password) {
+    UUID
+  }
+}`)
+	req.Var("token", token)
+	req.Var("password", password)
+
+	// set header fields
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct{}
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		data["expired"] = true
+	} else {
+		data["done"] = true
+	}
+	return c.Render(http.StatusOK, "reset-password.html", data)
+}
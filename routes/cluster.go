@@ -0,0 +1,461 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+)
+
+// clusterNode 群集，Credential 是凭据的引用名，口令本身只保存在后端
+type clusterNode struct {
+	UUID       string
+	Alias      string
+	Host       string
+	IP         string
+	Port       uint16
+	Credential string
+	Status     uint8 // 1 启用，0 停用
+	CreateAt   uint
+	UpdateAt   uint
+}
+
+// probeResult 连接测试的结果
+type probeResult struct {
+	OK      bool
+	Latency float64 // 往返耗时，单位毫秒
+	Version string
+	Role    string // 复制角色，如 master、slave
+	Message string
+}
+
+func clusters(c echo.Context) error {
+	req := graphql.NewRequest(`query index {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  clusters (first: 100){
+    edges {
+      node {
+        UUID
+        Alias
+        Host
+        IP
+        Port
+        Credential
+        Status
+        CreateAt
+        UpdateAt
+      }
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me       viewer
+		Clusters struct {
+			Edges []struct {
+				Node clusterNode
+			}
+		}
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+
+	return c.Render(http.StatusOK, "clusters-list.html", map[string]interface{}{
+		"data":     resp,
+		"failures": failures(c),
+	})
+}
+
+// checkCluster 校验群集表单，返回提交给后端的输入
+func checkCluster(form map[string]string) (map[string]interface{}, error) {
+	if form["alias"] == "" || form["host"] == "" {
+		return nil, fmt.Errorf("请填写别名和主机名")
+	}
+	if net.ParseIP(form["ip"]) == nil {
+		return nil, fmt.Errorf("无效的 IP 地址: %s", form["ip"])
+	}
+	port, err := strconv.ParseUint(form["port"], 10, 16)
+	if err != nil || port == 0 {
+		return nil, fmt.Errorf("无效的端口: %s", form["port"])
+	}
+	return map[string]interface{}{
+		"Alias":      form["alias"],
+		"Host":       form["host"],
+		"IP":         form["ip"],
+		"Port":       port,
+		"Credential": form["credential"],
+	}, nil
+}
+
+// editCluster 新建或修改群集，action 为 test 时只测试连接不保存
+func editCluster(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	uuid := c.Param("uuid")
+	form := map[string]string{
+		"alias":      strings.TrimSpace(c.FormValue("alias")),
+		"host":       strings.TrimSpace(c.FormValue("host")),
+		"ip":         strings.TrimSpace(c.FormValue("ip")),
+		"port":       strings.TrimSpace(c.FormValue("port")),
+		"credential": strings.TrimSpace(c.FormValue("credential")),
+	}
+
+	var message string
+	var probe *probeResult
+	if c.Request().Method == http.MethodPost {
+		input, err := checkCluster(form)
+		switch {
+		case err != nil:
+			message = err.Error()
+		case c.FormValue("action") == "test":
+			// 编辑时表单中没有口令，后端根据 UUID 使用已保存的凭据
+			input["UUID"] = uuid
+			req := graphql.NewRequest(`query ($input: ClusterInput!) {
+  probe(input: $input) {
+    OK
+    Latency
+    Version
+    Role
+    Message
+  }
+}`)
+			req.Var("input", input)
+
+			// set header fields
+			req.Header.Set("Authentication", token)
+			req.Header.Set("Cache-Control", "no-cache")
+
+			var resp struct {
+				Probe probeResult
+			}
+			if err := request(req, &resp); err != nil {
+				log.Println(err)
+				resp.Probe.Message = err.Error()
+			}
+			probe = &resp.Probe
+		default:
+			var req *graphql.Request
+			if uuid == "" {
+				req = graphql.NewRequest(`mutation ($input: ClusterInput!) {
+  createCluster(input: $input) {
+    UUID
+  }
+}`)
+			} else {
+				req = graphql.NewRequest(`mutation ($uuid: String! $input: ClusterInput!) {
+  updateCluster(UUID: $uuid, input: $input) {
+    UUID
+  }
+}`)
+				req.Var("uuid", uuid)
+			}
+			req.Var("input", input)
+
+			// set header fields
+			req.Header.Set("Authentication", token)
+			req.Header.Set("Cache-Control", "no-cache")
+
+			var resp struct{}
+			if err := request(req, &resp); err != nil {
+				log.Println(err)
+				message = err.Error()
+			} else {
+				return c.Redirect(http.StatusFound, "/clusters-list.html")
+			}
+		}
+	}
+
+	req := graphql.NewRequest(`query index ($uuid: String! $fetch: Boolean!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  cluster (UUID: $uuid) @include(if: $fetch) {
+    UUID
+    Alias
+    Host
+    IP
+    Port
+    Credential
+    Status
+    CreateAt
+    UpdateAt
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+	req.Var("uuid", uuid)
+	req.Var("fetch", uuid != "")
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me      viewer
+		Cluster *clusterNode
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+	if uuid != "" && resp.Cluster == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	if n := resp.Cluster; n != nil && c.Request().Method != http.MethodPost {
+		form["alias"] = n.Alias
+		form["host"] = n.Host
+		form["ip"] = n.IP
+		form["port"] = strconv.Itoa(int(n.Port))
+		form["credential"] = n.Credential
+	}
+	if form["port"] == "" {
+		form["port"] = "3306"
+	}
+
+	return c.Render(http.StatusOK, "create-cluster.html", map[string]interface{}{
+		"data":    resp,
+		"form":    form,
+		"uuid":    uuid,
+		"probe":   probe,
+		"message": message,
+	})
+}
+
+// enableCluster 启用群集
+func enableCluster(c echo.Context) error {
+	return mutate(c, "/clusters-list.html", `mutation ($uuid: String!) {
+  enableCluster(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
+
+// disableCluster 停用群集，停用后不能再对其发起工单和查询
+func disableCluster(c echo.Context) error {
+	return mutate(c, "/clusters-list.html", `mutation ($uuid: String!) {
+  disableCluster(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
+
+// removeCluster 删除群集
+func removeCluster(c echo.Context) error {
+	return mutate(c, "/clusters-list.html", `mutation ($uuid: String!) {
+  removeCluster(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
+
+// instance 复制拓扑中的一个实例，不一定在平台中登记为群集
+type instance struct {
+	Host       string
+	IP         string
+	Port       uint16
+	Role       string
+	Version    string
+	Lag        *uint // 复制延迟，单位秒，未知时为空
+	IORunning  bool
+	SQLRunning bool
+}
+
+// cluster 群集详情，包括库表统计、实例健康状况、复制拓扑和最近的工单
+func cluster(c echo.Context) error {
+	req := graphql.NewRequest(`query index ($uuid: String!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  cluster (UUID: $uuid) {
+    ...ClusterInfo
+    Credential
+    Status
+    CreateAt
+    UpdateAt
+    Health {
+      Version
+      Role
+      Uptime
+      Connections
+      MaxConnections
+      Running
+      SlowQueries
+      QPS
+    }
+    Schemas {
+      Name
+      Tables
+      Size
+    }
+    Master {
+      ...InstanceInfo
+    }
+    Replicas {
+      ...InstanceInfo
+      Lag
+      IORunning
+      SQLRunning
+    }
+    Tickets (first: 10) {
+      edges {
+        node {
+          ...TicketInfo
+        }
+      }
+    }
+  }
+}
+fragment TicketInfo on Ticket {
+  UUID
+  Subject
+  Database
+  Status
+  CreateAt
+  UpdateAt
+  User {
+    ...UserInfo
+  }
+  Reviewer {
+    ...UserInfo
+  }
+  Cluster {
+    ...ClusterInfo
+  }
+}
+fragment InstanceInfo on Instance {
+  Host
+  IP
+  Port
+  Role
+  Version
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+fragment ClusterInfo on Cluster {
+  UUID
+  Alias
+  Host
+  IP
+  Port
+}
+`)
+	req.Var("uuid", c.Param("uuid"))
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me      viewer
+		Cluster *struct {
+			clusterNode
+			Health struct {
+				Version        string
+				Role           string
+				Uptime         uint // 单位秒
+				Connections    uint
+				MaxConnections uint
+				Running        uint // 正在执行的线程数
+				SlowQueries    uint // 最近 24 小时的慢查询数
+				QPS            float64
+			}
+			Schemas []struct {
+				Name   string
+				Tables uint
+				Size   uint64
+			}
+			Master   *instance
+			Replicas []instance
+			Tickets  ticketEdges
+		}
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+	if resp.Cluster == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	var tables uint
+	var size uint64
+	sizes := map[string]string{}
+	for _, s := range resp.Cluster.Schemas {
+		tables += s.Tables
+		size += s.Size
+		sizes[s.Name] = FileSize(int64(s.Size))
+	}
+
+	// 连接数占最大连接数的百分比
+	var usage float64
+	if h := resp.Cluster.Health; h.MaxConnections > 0 {
+		usage = float64(h.Connections) * 100 / float64(h.MaxConnections)
+	}
+
+	return c.Render(http.StatusOK, "cluster.html", map[string]interface{}{
+		"data":     resp,
+		"failures": failures(c),
+		"tables":   tables,
+		"size":     FileSize(int64(size)),
+		"sizes":    sizes,
+		"usage":    usage,
+		"uptime":   time.Duration(resp.Cluster.Health.Uptime) * time.Second,
+	})
+}
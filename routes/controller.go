@@ -7,6 +7,7 @@ import (
 	"log"
 	"math"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/labstack/echo-contrib/session"
@@ -18,6 +19,51 @@ var (
 	startTime = time.Now()
 )
 
+// viewer 当前登录用户，菜单栏等公共部分依赖这些字段
+type viewer struct {
+	UUID   string
+	Name   string
+	Avatar struct {
+		URL string
+	}
+	Badges struct {
+		Submitted uint // 我提交的、尚未完结的工单数
+		Reviewing uint // 等待我审核的工单数
+	}
+	Permissions []string // 当前用户的角色所拥有的全部权限，由后端汇总
+}
+
+// Can 判断当前用户是否拥有指定权限，菜单等模板中也会调用
+func (v viewer) Can(permission string) bool {
+	for _, p := range v.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchViewer 读取当前用户及其权限，用于只需要做权限判断、不渲染页面的请求
+func fetchViewer(token string) (viewer, error) {
+	req := graphql.NewRequest(`query {
+  me {
+    UUID
+    Name
+    Permissions
+  }
+}`)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me viewer
+	}
+	err := request(req, &resp)
+	return resp.Me, err
+}
+
 func init() {
 }
 
@@ -69,6 +115,11 @@ func dashboard(c echo.Context) error {
   }
   me {
     ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
   }
   environments {
     CPUStats {
@@ -155,10 +206,7 @@ fragment ClusterInfo on Cluster {
 	req.Header.Set("Cache-Control", "no-cache")
 
 	var resp struct {
-		Me struct {
-			UUID string
-			Name string
-		}
+		Me         viewer
 		Statistics []struct {
 			Group string
 			Key   string
@@ -262,104 +310,87 @@ fragment ClusterInfo on Cluster {
 	})
 }
 
-func users(c echo.Context) error {
+func tickets(c echo.Context) error {
+	return c.Render(http.StatusOK, "tickets-list.html", map[string]interface{}{
+		"name": "Dolly!",
+	})
+}
+
+// ticketEdges 工单列表，与查询中的 TicketInfo 片段对应
+type ticketEdges struct {
+	Edges []struct {
+		Node struct {
+			UUID     string
+			Subject  string
+			Database string
+			Status   uint8
+			CreateAt uint
+			UpdateAt uint
+			User     struct {
+				Name   string
+				UUID   string
+				Avatar struct {
+					URL string
+				}
+			}
+			Reviewer struct {
+				Name   string
+				UUID   string
+				Avatar struct {
+					URL string
+				}
+			}
+			Cluster struct {
+				UUID  string
+				Alias string
+				Host  string
+				IP    string
+				Port  uint16
+			}
+		}
+	}
+}
+
+func userTickets(c echo.Context) error {
 	req := graphql.NewRequest(`query index {
   me {
     ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
   }
-  users (first: 15){
+  submitted: myTickets(first: 15) {
     edges {
       node {
-        UUID
-        Name
-		Email
-		Status
-        Avatar {
-          URL
-        }
-        Phone
-        CreateAt
+        ...TicketInfo
+      }
+    }
+  }
+  reviewing: myReviews(first: 15) {
+    edges {
+      node {
+        ...TicketInfo
       }
     }
   }
 }
-fragment UserInfo on User {
-  Name
+fragment TicketInfo on Ticket {
   UUID
-  Avatar {
-    URL
+  Subject
+  Database
+  Status
+  CreateAt
+  UpdateAt
+  User {
+    ...UserInfo
   }
-}
-`)
-
-	// set any variables
-	// req.Var("email", "ces365@163.com")
-	// req.Var("password", "root")
-
-	sess, _ := session.Get("session", c)
-	token, _ := sess.Values["token"].(string)
-
-	// set header fields
-	req.Header.Set("Authentication", token)
-	req.Header.Set("Cache-Control", "no-cache")
-
-	var resp struct {
-		Me struct {
-			UUID   string
-			Name   string
-			Avatar struct {
-				URL string
-			}
-		}
-		Users struct {
-			Edges []struct {
-				Node struct {
-					UUID     string
-					Name     string
-					Email    string
-					Phone    uint64
-					CreateAt uint
-					Status   uint8
-					Avatar   struct {
-						URL string
-					}
-				}
-			}
-		}
-	}
-
-	if err := request(req, &resp); err != nil {
-		log.Println(err)
-	}
-
-	return c.Render(http.StatusOK, "users-list.html", map[string]interface{}{
-		"data": resp,
-	})
-}
-
-func tickets(c echo.Context) error {
-	return c.Render(http.StatusOK, "tickets-list.html", map[string]interface{}{
-		"name": "Dolly!",
-	})
-}
-
-func rules(c echo.Context) error {
-	req := graphql.NewRequest(`query index {
-  me {
+  Reviewer {
     ...UserInfo
   }
-  rules {
-    UUID
-    Name
-    Group
-    Description
-    VldrGroup
-    Values
-    Bitwise
-    Func
-    Element
-    CreateAt
-    UpdateAt
+  Cluster {
+    ...ClusterInfo
   }
 }
 fragment UserInfo on User {
@@ -369,12 +400,15 @@ fragment UserInfo on User {
     URL
   }
 }
+fragment ClusterInfo on Cluster {
+  UUID
+  Alias
+  Host
+  IP
+  Port
+}
 `)
 
-	// set any variables
-	// req.Var("email", "ces365@163.com")
-	// req.Var("password", "root")
-
 	sess, _ := session.Get("session", c)
 	token, _ := sess.Values["token"].(string)
 
@@ -383,53 +417,53 @@ fragment UserInfo on User {
 	req.Header.Set("Cache-Control", "no-cache")
 
 	var resp struct {
-		Me struct {
-			UUID   string
-			Name   string
-			Avatar struct {
-				URL string
-			}
-		}
-		Rules []struct {
-			UUID        string
-			Name        string
-			Group       uint8
-			Description string
-			VldrGroup   uint16
-			Values      string
-			Bitwise     uint8
-			Func        string
-			Element     string
-			CreateAt    uint
-			UpdateAt    uint
-		}
+		Me        viewer
+		Submitted ticketEdges
+		Reviewing ticketEdges
 	}
 
 	if err := request(req, &resp); err != nil {
 		log.Println(err)
 	}
 
-	return c.Render(http.StatusOK, "rules-list.html", map[string]interface{}{
+	// 默认展示等待我审核的工单，便于审核人及时处理
+	tab := c.QueryParam("tab")
+	if tab != "submitted" {
+		tab = "reviewing"
+	}
+
+	return c.Render(http.StatusOK, "user-tickets.html", map[string]interface{}{
 		"data": resp,
+		"tab":  tab,
 	})
 }
 
-func clusters(c echo.Context) error {
-	req := graphql.NewRequest(`query index {
+func ticket(c echo.Context) error {
+	req := graphql.NewRequest(`query index ($uuid: String!) {
   me {
     ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
   }
-  clusters (first: 15){
-    edges {
-      node {
-        UUID
-        Alias
-        Host
-        IP
-        Port
-        Status
-        CreateAt
-      }
+  ticket (UUID: $uuid) {
+    UUID
+    Subject
+    Content
+    Database
+    Status
+    CreateAt
+    UpdateAt
+    User {
+      ...UserInfo
+    }
+    Reviewer {
+      ...UserInfo
+    }
+    Cluster {
+      ...ClusterInfo
     }
   }
 }
@@ -440,11 +474,15 @@ fragment UserInfo on User {
     URL
   }
 }
+fragment ClusterInfo on Cluster {
+  UUID
+  Alias
+  Host
+  IP
+  Port
+}
 `)
-
-	// set any variables
-	// req.Var("email", "ces365@163.com")
-	// req.Var("password", "root")
+	req.Var("uuid", c.Param("uuid"))
 
 	sess, _ := session.Get("session", c)
 	token, _ := sess.Values["token"].(string)
@@ -454,61 +492,51 @@ fragment UserInfo on User {
 	req.Header.Set("Cache-Control", "no-cache")
 
 	var resp struct {
-		Me struct {
-			UUID   string
-			Name   string
-			Avatar struct {
-				URL string
+		Me     viewer
+		Ticket *struct {
+			UUID     string
+			Subject  string
+			Content  string
+			Database string
+			Status   uint8
+			CreateAt uint
+			UpdateAt uint
+			User     struct {
+				Name   string
+				UUID   string
+				Avatar struct {
+					URL string
+				}
 			}
-		}
-		Clusters struct {
-			Edges []struct {
-				Node struct {
-					UUID     string
-					Alias    string
-					Host     string
-					IP       string
-					CreateAt uint
-					Status   uint8
-					Port     uint16
+			Reviewer struct {
+				Name   string
+				UUID   string
+				Avatar struct {
+					URL string
 				}
 			}
+			Cluster struct {
+				UUID  string
+				Alias string
+				Host  string
+				IP    string
+				Port  uint16
+			}
 		}
 	}
 
 	if err := request(req, &resp); err != nil {
 		log.Println(err)
 	}
+	if resp.Ticket == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
 
-	return c.Render(http.StatusOK, "clusters-list.html", map[string]interface{}{
+	return c.Render(http.StatusOK, "ticket.html", map[string]interface{}{
 		"data": resp,
 	})
 }
 
-func queries(c echo.Context) error {
-	return c.Render(http.StatusOK, "queries-list.html", map[string]interface{}{
-		"name": "Dolly!",
-	})
-}
-
-func crons(c echo.Context) error {
-	return c.Render(http.StatusOK, "crons-list.html", map[string]interface{}{
-		"name": "Dolly!",
-	})
-}
-
-func tasks(c echo.Context) error {
-	return c.Render(http.StatusOK, "tasks-list.html", map[string]interface{}{
-		"name": "Dolly!",
-	})
-}
-
-func options(c echo.Context) error {
-	return c.Render(http.StatusOK, "options-list.html", map[string]interface{}{
-		"name": "Dolly!",
-	})
-}
-
 func currencies(c echo.Context) error {
 	return c.Render(http.StatusOK, "crypto-currencies.html", map[string]interface{}{
 		"name": "Dolly!",
@@ -539,78 +567,6 @@ func sample(c echo.Context) error {
 	})
 }
 
-func login(c echo.Context) error {
-	switch c.Request().Method {
-	case "GET":
-		return c.Render(http.StatusOK, "login.html", map[string]interface{}{
-			"name": "Dolly!",
-		})
-	case "POST":
-		req := graphql.NewRequest(`mutation ($email: String! $password: String!) {
-		login (
-			input: {
-				Email: $email
-				Password: $password
-			}
-		) {
-			Me {
-				UUID
-				Name
-				Phone
-			}
-			Token
-		}
-	}`)
-
-		// set any variables
-		req.Var("email", "ces365@163.com")
-		req.Var("password", "root")
-
-		// set header fields
-		req.Header.Set("Cache-Control", "no-cache")
-
-		var resp struct {
-			Login struct {
-				Me struct {
-					UUID     string
-					Email    string
-					Password string
-					Status   uint8
-					Name     string
-					Phone    uint64
-					UpdateAt uint
-					CreateAt uint
-				}
-				Token string
-			}
-		}
-
-		if err := request(req, &resp); err != nil {
-			log.Println(err)
-		}
-
-		sess, _ := session.Get("session", c)
-		sess.Values["token"] = resp.Login.Token
-		sess.Save(c.Request(), c.Response())
-		return c.Redirect(http.StatusFound, "index.html")
-	default:
-		fmt.Println(c.Request().Method)
-		return echo.NewHTTPError(http.StatusBadRequest, "Method not allowed.")
-	}
-}
-
-func register(c echo.Context) error {
-	return c.Render(http.StatusOK, "register.html", map[string]interface{}{
-		"name": "Dolly!",
-	})
-}
-
-func forgot(c echo.Context) error {
-	return c.Render(http.StatusOK, "forgot-password.html", map[string]interface{}{
-		"name": "Dolly!",
-	})
-}
-
 func error400(c echo.Context) error {
 	return c.Render(http.StatusOK, "400.html", map[string]interface{}{
 		"name": "Dolly!",
@@ -660,6 +616,52 @@ func about(c echo.Context) error {
 	})
 }
 
+// mutate 执行只需要 UUID 参数的变更，完成后返回来源页面
+func mutate(c echo.Context, fallback, mutation string) error {
+	req := graphql.NewRequest(mutation)
+	req.Var("uuid", c.Param("uuid"))
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct{}
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		fail(c, "操作失败: "+err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, back(c, fallback))
+}
+
+// fail 保存一条错误提示，跳转后在下一个页面的顶部显示
+func fail(c echo.Context, message string) {
+	sess, _ := session.Get("session", c)
+	sess.AddFlash(message, "failures")
+	sess.Save(c.Request(), c.Response())
+}
+
+// failures 取出 fail 保存的错误提示，取出后即删除
+func failures(c echo.Context) []interface{} {
+	sess, _ := session.Get("session", c)
+	messages := sess.Flashes("failures")
+	if len(messages) > 0 {
+		sess.Save(c.Request(), c.Response())
+	}
+	return messages
+}
+
+// back 返回站内的来源页面，用于表单提交后跳转回原页面
+func back(c echo.Context, fallback string) string {
+	if u, err := url.Parse(c.Request().Referer()); err == nil && u.Path != "" && u.Host == c.Request().Host {
+		return u.RequestURI()
+	}
+	return fallback
+}
+
 func request(req *graphql.Request, resp interface{}) (err error) {
 	option := graphql.WithHTTPClient(&http.Client{
 		Transport: &http.Transport{
@@ -0,0 +1,263 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+
+	"github.com/mia0x75/venus/cron"
+)
+
+// previewRuns 预览的执行次数
+const previewRuns = 10
+
+// cronNode 预约任务
+type cronNode struct {
+	UUID      string
+	Status    string
+	Name      string
+	Cmd       string
+	Params    string
+	Interval  string // cron 表达式
+	Duration  string
+	LastRun   string
+	NextRun   string
+	Recurrent uint8
+	UpdateAt  uint
+	CreateAt  uint
+	User      struct {
+		Name   string
+		UUID   string
+		Avatar struct {
+			URL string
+		}
+	}
+}
+
+// cronFields 与 cronNode 对应的查询字段
+const cronFields = `
+    UUID
+    Status
+    Name
+    Cmd
+    Params
+    Interval
+    Duration
+    LastRun
+    NextRun
+    Recurrent
+    UpdateAt
+    CreateAt
+    User {
+      ...UserInfo
+    }`
+
+func crons(c echo.Context) error {
+	req := graphql.NewRequest(`query index {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  crons (first: 100){
+    edges {
+      node {` + cronFields + `
+      }
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me    viewer
+		Crons struct {
+			Edges []struct {
+				Node cronNode
+			}
+		}
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+
+	return c.Render(http.StatusOK, "crons-list.html", map[string]interface{}{
+		"data":     resp,
+		"failures": failures(c),
+	})
+}
+
+// editCron 新建或修改预约，action 为 preview 时只校验表达式并预览执行时间
+func editCron(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	uuid := c.Param("uuid")
+	form := map[string]string{
+		"name":      strings.TrimSpace(c.FormValue("name")),
+		"cmd":       strings.TrimSpace(c.FormValue("cmd")),
+		"params":    c.FormValue("params"),
+		"interval":  strings.TrimSpace(c.FormValue("interval")),
+		"recurrent": c.FormValue("recurrent"),
+	}
+
+	var message string
+	if c.Request().Method == http.MethodPost {
+		_, err := cron.Parse(form["interval"])
+		switch {
+		case err != nil:
+			message = err.Error()
+		case c.FormValue("action") == "preview":
+		case form["name"] == "" || form["cmd"] == "":
+			message = "请填写预约名称和命令"
+		default:
+			input := map[string]interface{}{
+				"Name":      form["name"],
+				"Cmd":       form["cmd"],
+				"Params":    form["params"],
+				"Interval":  form["interval"],
+				"Recurrent": form["recurrent"] == "1",
+			}
+			var req *graphql.Request
+			if uuid == "" {
+				req = graphql.NewRequest(`mutation ($input: CronInput!) {
+  createCron(input: $input) {
+    UUID
+  }
+}`)
+			} else {
+				req = graphql.NewRequest(`mutation ($uuid: String! $input: CronInput!) {
+  updateCron(UUID: $uuid, input: $input) {
+    UUID
+  }
+}`)
+				req.Var("uuid", uuid)
+			}
+			req.Var("input", input)
+
+			// set header fields
+			req.Header.Set("Authentication", token)
+			req.Header.Set("Cache-Control", "no-cache")
+
+			var resp struct{}
+			if err := request(req, &resp); err != nil {
+				log.Println(err)
+				message = err.Error()
+			} else {
+				return c.Redirect(http.StatusFound, "/crons-list.html")
+			}
+		}
+	}
+
+	req := graphql.NewRequest(`query index ($uuid: String! $fetch: Boolean!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  cron (UUID: $uuid) @include(if: $fetch) {` + cronFields + `
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+	req.Var("uuid", uuid)
+	req.Var("fetch", uuid != "")
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me   viewer
+		Cron *cronNode
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+	if uuid != "" && resp.Cron == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	if j := resp.Cron; j != nil && c.Request().Method != http.MethodPost {
+		form["name"] = j.Name
+		form["cmd"] = j.Cmd
+		form["params"] = j.Params
+		form["interval"] = j.Interval
+		if j.Recurrent == 1 {
+			form["recurrent"] = "1"
+		}
+	}
+
+	// 表达式有效时预览接下来的执行时间
+	var runs []time.Time
+	if s, err := cron.Parse(form["interval"]); err == nil {
+		runs = cron.NextN(s, time.Now(), previewRuns)
+	}
+
+	return c.Render(http.StatusOK, "create-cron.html", map[string]interface{}{
+		"data":    resp,
+		"form":    form,
+		"uuid":    uuid,
+		"runs":    runs,
+		"message": message,
+	})
+}
+
+// pauseCron 暂停预约
+func pauseCron(c echo.Context) error {
+	return mutate(c, "/crons-list.html", `mutation ($uuid: String!) {
+  pauseCron(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
+
+// resumeCron 恢复已暂停的预约
+func resumeCron(c echo.Context) error {
+	return mutate(c, "/crons-list.html", `mutation ($uuid: String!) {
+  resumeCron(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
+
+// removeCron 删除预约
+func removeCron(c echo.Context) error {
+	return mutate(c, "/crons-list.html", `mutation ($uuid: String!) {
+  removeCron(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
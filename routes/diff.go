@@ -0,0 +1,108 @@
+package routes
+
+import (
+	"strings"
+)
+
+// diffLine 差异比较结果中的一行
+type diffLine struct {
+	Op    string // " " 相同，"-" 仅在原文中，"+" 仅在新文中
+	Text  string
+	Left  int // 原文行号，0 表示不存在
+	Right int // 新文行号，0 表示不存在
+}
+
+// maxDiffCells 最长公共子序列表格的最大单元数，超出时不再逐行比较，避免占用过多内存
+const maxDiffCells = 1 << 20
+
+// diff 基于最长公共子序列比较两组文本行
+func diff(a, b []string) []diffLine {
+	// 相同的开头和结尾直接输出，只对中间不同的部分计算
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, diffLine{Op: " ", Text: a[i], Left: i + 1, Right: i + 1})
+	}
+	lines = append(lines, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix)...)
+	for k := suffix; k > 0; k-- {
+		i, j := len(a)-k, len(b)-k
+		lines = append(lines, diffLine{Op: " ", Text: a[i], Left: i + 1, Right: j + 1})
+	}
+	return lines
+}
+
+// diffMiddle 比较去掉相同首尾后的部分，offset 为行号的偏移
+func diffMiddle(a, b []string, offset int) []diffLine {
+	lines := make([]diffLine, 0, len(a)+len(b))
+	// 差异过大时整体显示为删除和新增
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		for i := range a {
+			lines = append(lines, diffLine{Op: "-", Text: a[i], Left: offset + i + 1})
+		}
+		for j := range b {
+			lines = append(lines, diffLine{Op: "+", Text: b[j], Right: offset + j + 1})
+		}
+		return lines
+	}
+
+	// lcs[i][j] 为 a[i:] 和 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{Op: " ", Text: a[i], Left: offset + i + 1, Right: offset + j + 1})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{Op: "-", Text: a[i], Left: offset + i + 1})
+			i++
+		default:
+			lines = append(lines, diffLine{Op: "+", Text: b[j], Right: offset + j + 1})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{Op: "-", Text: a[i], Left: offset + i + 1})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{Op: "+", Text: b[j], Right: offset + j + 1})
+	}
+	return lines
+}
+
+// splitLines 按行拆分文本，去掉行尾空白和首尾空行
+func splitLines(s string) []string {
+	s = strings.Trim(strings.Replace(s, "\r\n", "\n", -1), "\n")
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return lines
+}
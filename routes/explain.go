@@ -0,0 +1,339 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+)
+
+// planNode EXPLAIN FORMAT=JSON 输出中的一个节点
+type planNode struct {
+	Name         string
+	Table        string
+	AccessType   string
+	Key          string
+	PossibleKeys []string
+	UsedKeyParts []string
+	Rows         float64 // rows_examined_per_scan
+	Produced     float64 // rows_produced_per_join
+	Filtered     float64
+	Cost         float64
+	Share        float64 // 占整个查询成本的百分比
+	Condition    string
+	Extra        []string
+	Warnings     []string
+	Children     []*planNode
+}
+
+// 需要单独处理的表级字段，其余字段作为子节点递归展开
+var planTableKeys = map[string]bool{
+	"table_name":             true,
+	"access_type":            true,
+	"key":                    true,
+	"possible_keys":          true,
+	"used_key_parts":         true,
+	"key_length":             true,
+	"ref":                    true,
+	"rows_examined_per_scan": true,
+	"rows_produced_per_join": true,
+	"filtered":               true,
+	"cost_info":              true,
+	"attached_condition":     true,
+	"used_columns":           true,
+	"select_id":              true,
+	"message":                true,
+}
+
+// 执行计划中的布尔标志对应的 Extra 文本
+var planFlags = map[string]string{
+	"using_filesort":        "Using filesort",
+	"using_temporary_table": "Using temporary",
+	"using_index":           "Using index",
+	"using_where":           "Using where",
+	"using_join_buffer":     "Using join buffer",
+	"using_MRR":             "Using MRR",
+	"using_index_condition": "Using index condition",
+	"distinct":              "Distinct",
+	"dependent":             "Dependent",
+	"cacheable":             "Cacheable",
+}
+
+// parsePlan 解析 EXPLAIN FORMAT=JSON 的输出
+func parsePlan(plan string) (*planNode, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(plan), &doc); err != nil {
+		return nil, fmt.Errorf("解析执行计划失败: %s", err.Error())
+	}
+	root := walkPlan("query", doc)
+	if len(root.Children) == 1 {
+		root = root.Children[0]
+	}
+	if root.Cost > 0 {
+		root.share(root.Cost)
+	}
+	return root, nil
+}
+
+func walkPlan(name string, obj map[string]interface{}) *planNode {
+	n := &planNode{Name: name}
+	if id, ok := obj["select_id"]; ok {
+		n.Name = fmt.Sprintf("%s #%v", name, id)
+	}
+	if msg, ok := obj["message"].(string); ok {
+		n.Extra = append(n.Extra, msg)
+	}
+	if t, ok := obj["table_name"].(string); ok {
+		n.Table = t
+		n.AccessType, _ = obj["access_type"].(string)
+		n.Key, _ = obj["key"].(string)
+		n.PossibleKeys = strs(obj["possible_keys"])
+		n.UsedKeyParts = strs(obj["used_key_parts"])
+		n.Rows = num(obj["rows_examined_per_scan"])
+		n.Produced = num(obj["rows_produced_per_join"])
+		n.Filtered = num(obj["filtered"])
+		n.Condition, _ = obj["attached_condition"].(string)
+	}
+	if info, ok := obj["cost_info"].(map[string]interface{}); ok {
+		// prefix_cost 是连接到这张表为止的累计成本，表节点只取本身的读取和计算成本
+		switch {
+		case n.Table != "":
+			n.Cost = num(info["read_cost"]) + num(info["eval_cost"])
+		case info["query_cost"] != nil:
+			n.Cost = num(info["query_cost"])
+		default:
+			n.Cost = num(info["sort_cost"])
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if planTableKeys[k] {
+			continue
+		}
+		switch v := obj[k].(type) {
+		case bool:
+			if text, ok := planFlags[k]; ok && v {
+				n.Extra = append(n.Extra, text)
+			}
+		case map[string]interface{}:
+			n.Children = append(n.Children, walkPlan(k, v))
+		case []interface{}:
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					// nested_loop、query_specifications 等数组的元素只有一个键，直接展开
+					if len(m) == 1 {
+						for ik, iv := range m {
+							if im, ok := iv.(map[string]interface{}); ok {
+								n.Children = append(n.Children, walkPlan(ik, im))
+							}
+						}
+						continue
+					}
+					n.Children = append(n.Children, walkPlan(k, m))
+				}
+			}
+		}
+	}
+	n.check()
+	return n
+}
+
+// check 标记需要关注的访问方式
+func (n *planNode) check() {
+	if n.Table != "" {
+		switch n.AccessType {
+		case "ALL":
+			n.Warnings = append(n.Warnings, "全表扫描")
+		case "index":
+			n.Warnings = append(n.Warnings, "全索引扫描")
+		}
+		if n.Key == "" && len(n.PossibleKeys) > 0 {
+			n.Warnings = append(n.Warnings, "存在可用索引但未使用")
+		}
+	}
+	for _, e := range n.Extra {
+		switch e {
+		case "Using filesort":
+			n.Warnings = append(n.Warnings, "使用了文件排序")
+		case "Using temporary":
+			n.Warnings = append(n.Warnings, "使用了临时表")
+		case "Using join buffer":
+			n.Warnings = append(n.Warnings, "关联未使用索引")
+		}
+	}
+}
+
+func (n *planNode) share(total float64) {
+	n.Share = n.Cost / total * 100
+	if n.Share > 100 {
+		n.Share = 100
+	}
+	for _, c := range n.Children {
+		c.share(total)
+	}
+}
+
+// num MySQL 5.7 的成本以字符串输出，行数以数字输出，这里统一转换为数字
+func num(v interface{}) float64 {
+	switch t := v.(type) {
+	case float64:
+		return t
+	case string:
+		f, _ := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f
+	}
+	return 0
+}
+
+func strs(v interface{}) []string {
+	items, _ := v.([]interface{})
+	ss := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+func analyzeQuery(c echo.Context) error {
+	form := map[string]string{
+		"cluster":  c.FormValue("cluster"),
+		"database": c.FormValue("database"),
+		"content":  c.FormValue("content"),
+	}
+	post := c.Request().Method == http.MethodPost
+
+	var message, stmt string
+	if post {
+		var err error
+		if stmt, err = checkReadOnly(form["content"]); err != nil {
+			message = err.Error()
+		} else if form["cluster"] == "" || form["database"] == "" {
+			message = "请选择目标群集和数据库"
+		}
+	}
+
+	req := graphql.NewRequest(`query index ($input: AnalyzeInput! $analyze: Boolean!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  clusters (first: 100){
+    edges {
+      node {
+        ...ClusterInfo
+        Databases
+      }
+    }
+  }
+  analyze (input: $input) @include(if: $analyze) {
+    Plan
+    Warnings {
+      Level
+      Code
+      Message
+    }
+    Suggestions {
+      Table
+      Columns
+      Reason
+      Statement
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+fragment ClusterInfo on Cluster {
+  UUID
+  Alias
+  Host
+  IP
+  Port
+}
+`)
+	req.Var("analyze", post && message == "")
+	req.Var("input", map[string]interface{}{
+		"ClusterUUID": form["cluster"],
+		"Database":    form["database"],
+		"Content":     stmt,
+	})
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me       viewer
+		Clusters struct {
+			Edges []struct {
+				Node struct {
+					UUID      string
+					Alias     string
+					Host      string
+					IP        string
+					Port      uint16
+					Databases []string
+				}
+			}
+		}
+		Analyze *struct {
+			Plan     string // EXPLAIN FORMAT=JSON 的原始输出
+			Warnings []struct {
+				Level   string
+				Code    uint
+				Message string
+			}
+			Suggestions []struct {
+				Table     string
+				Columns   []string
+				Reason    string
+				Statement string
+			}
+		}
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		message = err.Error()
+	}
+
+	var plan *planNode
+	if resp.Analyze != nil && resp.Analyze.Plan != "" {
+		var err error
+		if plan, err = parsePlan(resp.Analyze.Plan); err != nil {
+			message = err.Error()
+		}
+	}
+
+	return c.Render(http.StatusOK, "analyze-query.html", map[string]interface{}{
+		"data":    resp,
+		"form":    form,
+		"plan":    plan,
+		"message": message,
+	})
+}
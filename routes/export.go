@@ -0,0 +1,133 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+
+	"github.com/mia0x75/venus/export"
+	"github.com/mia0x75/venus/g"
+)
+
+// exportChunk 每次从后端获取的行数
+const exportChunk = 500
+
+// exportQuery 分批从后端读取查询结果并写入响应，任意时刻内存中最多只有一批数据
+func exportQuery(c echo.Context) error {
+	uuid := c.Param("uuid")
+	w, format, err := export.New(c.QueryParam("format"), c.Response())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+	limit := g.Config().Query.MaxRows
+
+	// fetch 获取从 offset 开始的一批数据，第一批同时获取当前用户和列定义
+	fetch := func(offset int, first bool) (me viewer, r *result, err error) {
+		req := graphql.NewRequest(`query index ($uuid: String! $offset: Int! $limit: Int! $first: Boolean!) {
+  me @include(if: $first) {
+    UUID
+    Name
+  }
+  query (UUID: $uuid) {
+    Result (Offset: $offset, Limit: $limit) {
+      Total
+      Columns @include(if: $first) {
+        Name
+        Type
+      }
+      Rows
+    }
+  }
+}`)
+		size := exportChunk
+		if offset+size > limit {
+			size = limit - offset
+		}
+		req.Var("uuid", uuid)
+		req.Var("offset", offset)
+		req.Var("limit", size)
+		req.Var("first", first)
+
+		// set header fields
+		req.Header.Set("Authentication", token)
+		req.Header.Set("Cache-Control", "no-cache")
+
+		var resp struct {
+			Me    viewer
+			Query *struct {
+				Result result
+			}
+		}
+		if err = request(req, &resp); err != nil || resp.Query == nil {
+			return resp.Me, nil, err
+		}
+		return resp.Me, &resp.Query.Result, nil
+	}
+
+	start := time.Now()
+	me, r, err := fetch(0, true)
+	if err != nil {
+		log.Printf("导出查询结果失败，查询: %s，错误信息: %s", uuid, err.Error())
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	if r == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	total := int(r.Total)
+	if total > limit {
+		total = limit
+	}
+	names := make([]string, len(r.Columns))
+	for i, col := range r.Columns {
+		names[i] = col.Name
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, format.ContentType)
+	res.Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="query-%s.%s"`, uuid, format.Extension))
+	res.WriteHeader(http.StatusOK)
+
+	rows := 0
+	err = w.Header(names)
+	for err == nil {
+		for _, row := range r.Rows {
+			if rows >= total {
+				break
+			}
+			if err = w.Row(row); err != nil {
+				break
+			}
+			rows++
+		}
+		res.Flush()
+		if err != nil || rows >= total || len(r.Rows) == 0 {
+			break
+		}
+		if _, r, err = fetch(rows, false); err == nil && r == nil {
+			err = fmt.Errorf("查询已不存在")
+		}
+	}
+	if err == nil {
+		err = w.Close()
+	}
+
+	// 审计日志，响应头已经发出，出错时只能记录日志并中断输出
+	audit := fmt.Sprintf("导出查询结果，用户: %s(%s)，查询: %s，格式: %s，行数: %d/%d，来源: %s，耗时: %s",
+		me.Name, me.UUID, uuid, format.Extension, rows, total, clientIP(c), time.Since(start))
+	if err != nil {
+		log.Printf("%s，中断: %s", audit, err.Error())
+		return nil
+	}
+	log.Println(audit)
+	return nil
+}
@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/mia0x75/venus/captcha"
+	"github.com/mia0x75/venus/g"
+)
+
+// captchaAfter 失败多少次之后需要输入图片验证码
+const captchaAfter = 3
+
+var (
+	// 登录失败次数，达到限制后临时锁定 15 分钟，再次达到限制时锁定时间逐次加倍，最长 24 小时
+	loginByAccount = newLimiter(10, 15*time.Minute)
+	loginByIP      = newLimiter(50, 15*time.Minute)
+	// 注册的提交次数，批量注册本身就是要防范的行为，所以成功的提交也计数
+	registerByIP = newLimiter(captchaAfter, time.Hour)
+)
+
+// needCaptcha 任意一个计数达到 captchaAfter 后需要输入验证码
+func needCaptcha(counts ...int) bool {
+	for _, n := range counts {
+		if n >= captchaAfter {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCaptcha 校验表单中的验证码，返回给用户看的错误信息，验证码无论对错都会作废
+func checkCaptcha(c echo.Context) string {
+	id := c.FormValue("captcha_id")
+	answer := strings.TrimSpace(c.FormValue("captcha"))
+	ok := captcha.Verify(id, answer)
+	switch {
+	case answer == "":
+		return "请输入验证码"
+	case !ok:
+		return "验证码错误"
+	}
+	return ""
+}
+
+// clientIP 客户端的地址，直接连接的是可信代理时才采用 X-Forwarded-For，
+// 从右往左跳过可信代理，取第一个不可信的地址，客户端自己添加的部分不会被采用
+func clientIP(c echo.Context) string {
+	ip, _, err := net.SplitHostPort(c.Request().RemoteAddr)
+	if err != nil {
+		ip = c.Request().RemoteAddr
+	}
+	if !trustedProxy(ip) {
+		return ip
+	}
+	hops := strings.Split(c.Request().Header.Get(echo.HeaderXForwardedFor), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !trustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// trustedProxy 是否为配置中的可信代理
+func trustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, p := range g.Config().Proxies {
+		if _, n, err := net.ParseCIDR(p); err == nil {
+			if n.Contains(addr) {
+				return true
+			}
+		} else if addr.Equal(net.ParseIP(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// lockMessage 临时锁定时的提示
+func lockMessage(wait time.Duration) string {
+	return fmt.Sprintf("尝试次数过多，已临时锁定，请 %d 分钟后再试", int(math.Ceil(wait.Minutes())))
+}
+
+// captchaImage 验证码图片，带 reload 参数时为同一个 ID 换一组数字
+func captchaImage(c echo.Context) error {
+	id := c.Param("id")
+	if c.QueryParam("reload") != "" && !captcha.Reload(id) {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	b, err := captcha.PNG(id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.Blob(http.StatusOK, "image/png", b)
+}
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/mia0x75/venus/g"
+)
+
+func TestClientIP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "venus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := filepath.Join(dir, "cfg.json")
+	if err := ioutil.WriteFile(cfg, []byte(`{
+	"proxies": ["127.0.0.1", "10.0.0.0/8"],
+	"secret": {"session": {"auth": "`+strings.Repeat("ab", 32)+`", "encrypt": "`+strings.Repeat("cd", 32)+`"}}
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g.ParseConfig(cfg)
+
+	tests := []struct {
+		remote    string
+		forwarded string
+		want      string
+	}{
+		// 不是可信代理时忽略 X-Forwarded-For
+		{"203.0.113.7:5000", "", "203.0.113.7"},
+		{"203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		// 经过可信代理时取最右边的不可信地址，客户端伪造的部分在左边
+		{"127.0.0.1:5000", "198.51.100.1", "198.51.100.1"},
+		{"127.0.0.1:5000", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"127.0.0.1:5000", "1.2.3.4, 198.51.100.1, 10.1.2.3", "198.51.100.1"},
+		{"127.0.0.1:5000", "garbage, 198.51.100.1", "198.51.100.1"},
+		// 全部是可信代理时取最左边的一个
+		{"127.0.0.1:5000", "10.1.2.3", "10.1.2.3"},
+		// 无法解析的地址到此为止
+		{"127.0.0.1:5000", "198.51.100.1, garbage", "127.0.0.1"},
+		{"127.0.0.1:5000", "", "127.0.0.1"},
+	}
+	e := echo.New()
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			req.Header.Set(echo.HeaderXForwardedFor, tt.forwarded)
+		}
+		c := e.NewContext(req, httptest.NewRecorder())
+		if got := clientIP(c); got != tt.want {
+			t.Errorf("%s %q: clientIP=%s，应为 %s", tt.remote, tt.forwarded, got, tt.want)
+		}
+	}
+}
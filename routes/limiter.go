@@ -0,0 +1,143 @@
+package routes
+
+import (
+	"sync"
+	"time"
+)
+
+// maxLockout 锁定时间的上限，锁定结束后这么长时间内没有再次锁定才清零锁定次数
+const maxLockout = 24 * time.Hour
+
+// limiter 滑动窗口限流器，按 key 分别计数，只保存在内存中，重启后清零
+type limiter struct {
+	sync.Mutex
+	limit  int
+	window time.Duration
+	hits   map[string][]time.Time
+	locks  map[string]*lockout
+	sweep  time.Time // 下次清理过期记录的时间
+}
+
+// lockout hit 的记录数达到限制后的锁定，第一次锁定一个时间窗口，之后每次加倍
+type lockout struct {
+	strikes int // 锁定的次数
+	until   time.Time
+}
+
+func newLimiter(limit int, window time.Duration) *limiter {
+	return &limiter{
+		limit:  limit,
+		window: window,
+		hits:   map[string][]time.Time{},
+		locks:  map[string]*lockout{},
+	}
+}
+
+// recent 返回窗口内的请求时间，顺便丢弃过期的记录，调用方需要持有锁
+func (l *limiter) recent(key string, now time.Time) []time.Time {
+	hits := l.hits[key]
+	i := 0
+	for i < len(hits) && now.Sub(hits[i]) >= l.window {
+		i++
+	}
+	hits = hits[i:]
+	if len(hits) == 0 {
+		delete(l.hits, key)
+	} else {
+		l.hits[key] = hits
+	}
+	return hits
+}
+
+// collect 定期清理所有 key 的过期记录，调用方需要持有锁
+func (l *limiter) collect(now time.Time) {
+	if now.After(l.sweep) {
+		for k := range l.hits {
+			l.recent(k, now)
+		}
+		for k, lock := range l.locks {
+			if now.Sub(lock.until) >= maxLockout {
+				delete(l.locks, k)
+			}
+		}
+		l.sweep = now.Add(l.window)
+	}
+}
+
+// allow 记录一次请求，窗口内的请求数超过限制时返回 false，被拒绝的请求不计数
+func (l *limiter) allow(key string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	l.collect(now)
+	if len(l.recent(key, now)) >= l.limit {
+		return false
+	}
+	l.hits[key] = append(l.hits[key], now)
+	return true
+}
+
+// hit 只记录不判断，用于统计失败次数，记录数达到限制时锁定，
+// 锁定时间随锁定次数加倍，只记录到期时间，不需要为等待占用 goroutine
+func (l *limiter) hit(key string) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	l.collect(now)
+	hits := append(l.recent(key, now), now)
+	l.hits[key] = hits
+	if len(hits) < l.limit {
+		return
+	}
+	lock := l.locks[key]
+	if lock == nil {
+		lock = &lockout{}
+		l.locks[key] = lock
+	}
+	if now.Before(lock.until) {
+		return
+	}
+	lock.strikes++
+	wait := l.window
+	for i := 1; i < lock.strikes && wait < maxLockout; i++ {
+		wait *= 2
+	}
+	if wait > maxLockout {
+		wait = maxLockout
+	}
+	lock.until = now.Add(wait)
+}
+
+// count 窗口内的记录数
+func (l *limiter) count(key string) int {
+	l.Lock()
+	defer l.Unlock()
+	return len(l.recent(key, time.Now()))
+}
+
+// locked 返回需要等待的时间，锁定中时为锁定的剩余时间，
+// 否则记录数达到限制时为最早一条记录移出窗口的时间
+func (l *limiter) locked(key string) (bool, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	if lock := l.locks[key]; lock != nil && now.Before(lock.until) {
+		return true, lock.until.Sub(now)
+	}
+	hits := l.recent(key, now)
+	if len(hits) < l.limit {
+		return false, 0
+	}
+	return true, hits[len(hits)-l.limit].Add(l.window).Sub(now)
+}
+
+// reset 清除 key 的全部记录和锁定次数
+func (l *limiter) reset(key string) {
+	l.Lock()
+	defer l.Unlock()
+	delete(l.hits, key)
+	delete(l.locks, key)
+}
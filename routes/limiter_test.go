@@ -0,0 +1,181 @@
+package routes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	tests := []struct {
+		limit int
+		calls int
+		want  int // 允许通过的次数
+	}{
+		{1, 3, 1},
+		{3, 2, 2},
+		{3, 3, 3},
+		{3, 10, 3},
+	}
+	for _, tt := range tests {
+		l := newLimiter(tt.limit, time.Hour)
+		allowed := 0
+		for i := 0; i < tt.calls; i++ {
+			if l.allow("a") {
+				allowed++
+			}
+		}
+		if allowed != tt.want {
+			t.Errorf("limit=%d calls=%d: 允许 %d 次，应为 %d", tt.limit, tt.calls, allowed, tt.want)
+		}
+		// 被拒绝的请求不计数
+		if n := l.count("a"); n != tt.want {
+			t.Errorf("limit=%d calls=%d: count=%d，应为 %d", tt.limit, tt.calls, n, tt.want)
+		}
+		if n := l.count("b"); n != 0 {
+			t.Errorf("limit=%d: 其他 key 的 count=%d，应为 0", tt.limit, n)
+		}
+	}
+}
+
+func TestLimiterHit(t *testing.T) {
+	l := newLimiter(2, time.Hour)
+	for i := 0; i < 5; i++ {
+		l.hit("a")
+	}
+	if n := l.count("a"); n != 5 {
+		t.Errorf("count=%d，应为 5", n)
+	}
+	if l.allow("a") {
+		t.Error("超过限制后 allow 应返回 false")
+	}
+}
+
+func TestLimiterWindow(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		ages []time.Duration // 每条记录距现在的时间
+		want int
+	}{
+		{nil, 0},
+		{[]time.Duration{90 * time.Minute, 70 * time.Minute}, 0},
+		{[]time.Duration{90 * time.Minute, 30 * time.Minute, time.Minute}, 2},
+		{[]time.Duration{time.Hour, 59 * time.Minute}, 1},
+	}
+	for _, tt := range tests {
+		l := newLimiter(10, time.Hour)
+		for _, age := range tt.ages {
+			l.hits["a"] = append(l.hits["a"], now.Add(-age))
+		}
+		if n := l.count("a"); n != tt.want {
+			t.Errorf("%v: count=%d，应为 %d", tt.ages, n, tt.want)
+		}
+	}
+}
+
+func TestLimiterLocked(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		limit  int
+		ages   []time.Duration
+		locked bool
+		wait   time.Duration // 大约需要等待的时间
+	}{
+		{3, []time.Duration{10 * time.Minute, 5 * time.Minute}, false, 0},
+		{3, []time.Duration{20 * time.Minute, 10 * time.Minute, 5 * time.Minute}, true, 40 * time.Minute},
+		// 超出限制时以倒数第 limit 条记录计算
+		{2, []time.Duration{50 * time.Minute, 20 * time.Minute, 10 * time.Minute}, true, 40 * time.Minute},
+		{2, []time.Duration{70 * time.Minute, 20 * time.Minute}, false, 0},
+	}
+	for _, tt := range tests {
+		l := newLimiter(tt.limit, time.Hour)
+		for _, age := range tt.ages {
+			l.hits["a"] = append(l.hits["a"], now.Add(-age))
+		}
+		locked, wait := l.locked("a")
+		if locked != tt.locked {
+			t.Errorf("limit=%d %v: locked=%v，应为 %v", tt.limit, tt.ages, locked, tt.locked)
+			continue
+		}
+		if d := wait - tt.wait; d > time.Second || d < -time.Second {
+			t.Errorf("limit=%d %v: wait=%v，应约为 %v", tt.limit, tt.ages, wait, tt.wait)
+		}
+	}
+}
+
+func TestLimiterReset(t *testing.T) {
+	l := newLimiter(2, time.Hour)
+	l.hit("a")
+	l.hit("a")
+	l.hit("b")
+	l.reset("a")
+	if n := l.count("a"); n != 0 {
+		t.Errorf("reset 后 count=%d，应为 0", n)
+	}
+	if n := l.count("b"); n != 1 {
+		t.Errorf("reset 不应影响其他 key，count=%d", n)
+	}
+	if !l.allow("a") {
+		t.Error("reset 后 allow 应返回 true")
+	}
+}
+
+func TestLimiterCollect(t *testing.T) {
+	l := newLimiter(10, time.Hour)
+	old := time.Now().Add(-2 * time.Hour)
+	for _, k := range []string{"a", "b", "c"} {
+		l.hits[k] = []time.Time{old}
+	}
+	l.hit("d")
+	if len(l.hits) != 1 {
+		t.Errorf("清理后剩余 %d 个 key，应为 1", len(l.hits))
+	}
+}
+
+func TestLimiterLockout(t *testing.T) {
+	l := newLimiter(2, time.Minute)
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute} {
+		l.hit("a")
+		if locked, _ := l.locked("a"); locked {
+			t.Fatal("未达到限制时不应锁定")
+		}
+		l.hit("a")
+		locked, wait := l.locked("a")
+		if !locked {
+			t.Fatal("达到限制后应当锁定")
+		}
+		if d := wait - want; d > time.Second || d < -time.Second {
+			t.Errorf("wait=%v，应约为 %v", wait, want)
+		}
+		// 模拟锁定到期，失败记录也已移出窗口
+		l.locks["a"].until = time.Now()
+		delete(l.hits, "a")
+	}
+
+	// 锁定时间不超过 maxLockout
+	l.locks["a"].strikes = 20
+	l.hit("a")
+	l.hit("a")
+	if _, wait := l.locked("a"); wait > maxLockout {
+		t.Errorf("wait=%v，不应超过 %v", wait, maxLockout)
+	}
+
+	// 登录成功后锁定次数清零
+	l.reset("a")
+	l.hit("a")
+	l.hit("a")
+	if _, wait := l.locked("a"); wait > time.Minute {
+		t.Errorf("reset 后 wait=%v，应重新从 %v 开始", wait, time.Minute)
+	}
+}
+
+func TestLimiterForgetLockout(t *testing.T) {
+	l := newLimiter(1, time.Minute)
+	l.hit("a")
+	l.locks["a"].until = time.Now().Add(-maxLockout)
+	delete(l.hits, "a")
+	l.sweep = time.Time{}
+	l.hit("b")
+	if _, ok := l.locks["a"]; ok {
+		t.Error("锁定结束超过 maxLockout 后应清零锁定次数")
+	}
+}
@@ -0,0 +1,254 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+)
+
+// optionNode 系统选项，值统一以字符串保存，Type 决定如何编辑和校验
+type optionNode struct {
+	Name        string
+	Section     string
+	Title       string
+	Description string
+	Type        string // bool、enum、set、int、duration，其他按字符串处理
+	Value       string
+	Default     string
+	Choices     []string // enum 和 set 的可选值
+	Min, Max    *int64   // int 的取值范围，为空表示不限
+	UpdateAt    uint
+	Updater     *struct { // 从未修改过时为空
+		Name   string
+		UUID   string
+		Avatar struct {
+			URL string
+		}
+	}
+}
+
+// optionValues 选项名到值的映射，用于回显表单
+type optionValues map[string]string
+
+// Has set 类型的选项是否选中了 choice，值以逗号分隔
+func (v optionValues) Has(name, choice string) bool {
+	for _, s := range strings.Split(v[name], ",") {
+		if s == choice {
+			return true
+		}
+	}
+	return false
+}
+
+// optionSection 同一分节的选项
+type optionSection struct {
+	Name    string
+	Options []optionNode
+}
+
+// groupOptions 按分节整理选项，保持后端返回的顺序
+func groupOptions(options []optionNode) []*optionSection {
+	index := map[string]*optionSection{}
+	var sections []*optionSection
+	for _, o := range options {
+		s, ok := index[o.Section]
+		if !ok {
+			s = &optionSection{Name: o.Section}
+			index[o.Section] = s
+			sections = append(sections, s)
+		}
+		s.Options = append(s.Options, o)
+	}
+	return sections
+}
+
+// checkOption 按选项的类型校验并规范化输入的值
+func checkOption(o optionNode, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch o.Type {
+	case "bool":
+		if value == "true" {
+			return "true", nil
+		}
+		return "false", nil
+	case "enum":
+		for _, c := range o.Choices {
+			if value == c {
+				return value, nil
+			}
+		}
+		return "", fmt.Errorf("请从列表中选择")
+	case "set":
+		// 按可选值的顺序排列，同时去掉重复和无效的值
+		selected := map[string]bool{}
+		for _, v := range strings.Split(value, ",") {
+			selected[strings.TrimSpace(v)] = true
+		}
+		var values []string
+		for _, c := range o.Choices {
+			if selected[c] {
+				values = append(values, c)
+			}
+		}
+		return strings.Join(values, ","), nil
+	case "int":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("请输入整数")
+		}
+		if o.Min != nil && n < *o.Min {
+			return "", fmt.Errorf("不能小于 %d", *o.Min)
+		}
+		if o.Max != nil && n > *o.Max {
+			return "", fmt.Errorf("不能大于 %d", *o.Max)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case "duration":
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return "", fmt.Errorf("请输入有效的时长，如 30s、5m、1h30m")
+		}
+		// 与原值表示同一时长时保留原来的写法，避免 5m 与 5m0s 被当作修改
+		if old, err := time.ParseDuration(o.Value); err == nil && old == d {
+			return o.Value, nil
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+// options 系统选项，按分节分别保存，只提交有变化的选项
+func options(c echo.Context) error {
+	req := graphql.NewRequest(`query index {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  options {
+    Name
+    Section
+    Title
+    Description
+    Type
+    Value
+    Default
+    Choices
+    Min
+    Max
+    UpdateAt
+    Updater {
+      ...UserInfo
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me      viewer
+		Options []optionNode
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+
+	values := optionValues{}
+	for _, o := range resp.Options {
+		values[o.Name] = o.Value
+	}
+
+	section := c.FormValue("section")
+	errs := map[string]string{}
+	var message string
+	if c.Request().Method == http.MethodPost {
+		var changes []map[string]interface{}
+		for _, o := range resp.Options {
+			if o.Section != section {
+				continue
+			}
+			// 未勾选的开关不会随表单提交，set 类型每个勾选项提交一个值
+			raw := c.FormValue(o.Name)
+			if o.Type == "set" {
+				params, _ := c.FormParams()
+				raw = strings.Join(params[o.Name], ",")
+			}
+			values[o.Name] = raw
+			v, err := checkOption(o, raw)
+			if err != nil {
+				errs[o.Name] = err.Error()
+				continue
+			}
+			values[o.Name] = v
+			if v != o.Value {
+				changes = append(changes, map[string]interface{}{
+					"Name":  o.Name,
+					"Value": v,
+				})
+			}
+		}
+
+		if len(errs) == 0 {
+			next := "/options-list.html?saved=" + url.QueryEscape(section)
+			if len(changes) == 0 {
+				return c.Redirect(http.StatusFound, next)
+			}
+
+			req := graphql.NewRequest(`mutation ($input: [OptionInput!]!) {
+  updateOptions(input: $input) {
+    Name
+  }
+}`)
+			req.Var("input", changes)
+
+			// set header fields
+			req.Header.Set("Authentication", token)
+			req.Header.Set("Cache-Control", "no-cache")
+
+			var resp struct{}
+			if err := request(req, &resp); err != nil {
+				log.Println(err)
+				message = err.Error()
+			} else {
+				forgetPublicOptions()
+				return c.Redirect(http.StatusFound, next)
+			}
+		}
+	}
+
+	return c.Render(http.StatusOK, "options-list.html", map[string]interface{}{
+		"data":     resp,
+		"sections": groupOptions(resp.Options),
+		"values":   values,
+		"section":  section,
+		"saved":    c.QueryParam("saved"),
+		"errors":   errs,
+		"message":  message,
+	})
+}
@@ -0,0 +1,319 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+)
+
+// tokenExpires 个人令牌可选的有效期，单位为天，0 表示永不过期
+var tokenExpires = []struct {
+	Days uint
+	Name string
+}{
+	{30, "30 天"},
+	{90, "90 天"},
+	{365, "一年"},
+	{0, "永不过期"},
+}
+
+// apiToken 个人 API 令牌，令牌本身只在创建时返回一次
+type apiToken struct {
+	UUID     string
+	Name     string
+	CreateAt uint
+	ExpireAt uint // 为 0 表示永不过期
+	LastUsed uint // 为 0 表示从未使用
+}
+
+// profileNode 当前用户的资料
+type profileNode struct {
+	Email        string
+	Phone        uint64
+	Roles        []string
+	TwoFactor    bool // 是否已启用两步验证
+	RecoveryLeft int  // 剩余可用的恢复码数量
+	Tokens       []apiToken
+}
+
+// saveProfile 修改姓名、电话和头像
+func saveProfile(token string, c echo.Context, form, errs map[string]string) error {
+	if form["name"] == "" {
+		errs["name"] = "请填写姓名"
+	}
+	var phone uint64
+	if form["phone"] != "" {
+		var err error
+		if phone, err = strconv.ParseUint(form["phone"], 10, 64); err != nil || len(form["phone"]) < 7 {
+			errs["phone"] = "无效的电话号码"
+		}
+	}
+	avatar, err := readAvatar(c)
+	if err != nil {
+		errs["avatar"] = err.Error()
+	} else if avatar != nil {
+		form["avatar"] = avatar.url
+	}
+	if len(errs) > 0 {
+		return nil
+	}
+	if err := avatar.write(); err != nil {
+		return err
+	}
+
+	req := graphql.NewRequest(`mutation ($input: ProfileInput!) {
+  updateProfile(input: $input) {
+    UUID
+  }
+}`)
+	req.Var("input", map[string]interface{}{
+		"Name":   form["name"],
+		"Phone":  phone,
+		"Avatar": form["avatar"],
+	})
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct{}
+	if err := request(req, &resp); err != nil {
+		avatar.discard()
+		return err
+	}
+	return nil
+}
+
+// changePassword 修改密码，需要验证当前密码
+func changePassword(token string, c echo.Context, errs map[string]string) error {
+	current, next := c.FormValue("current"), c.FormValue("password")
+	switch {
+	case current == "":
+		errs["current"] = "请输入当前密码"
+	case len(next) < 8:
+		errs["password"] = "密码至少需要 8 个字符"
+	case next == current:
+		errs["password"] = "新密码不能与当前密码相同"
+	case next != c.FormValue("confirm"):
+		errs["confirm"] = "两次输入的密码不一致"
+	}
+	if len(errs) > 0 {
+		return nil
+	}
+
+	req := graphql.NewRequest(`mutation ($old: String! $new: String!) {
+  changePassword(Old: $old, New: $new) {
+    UUID
+  }
+}`)
+	req.Var("old", current)
+	req.Var("new", next)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct{}
+	return request(req, &resp)
+}
+
+// createToken 创建个人令牌，返回令牌明文
+func createToken(token string, c echo.Context, errs map[string]string) (string, error) {
+	name := strings.TrimSpace(c.FormValue("token_name"))
+	days, err := strconv.ParseUint(c.FormValue("expires"), 10, 32)
+	valid := false
+	for _, e := range tokenExpires {
+		valid = valid || (err == nil && uint64(e.Days) == days)
+	}
+	if name == "" {
+		errs["token_name"] = "请填写令牌用途"
+	}
+	if !valid {
+		errs["expires"] = "请选择有效期"
+	}
+	if len(errs) > 0 {
+		return "", nil
+	}
+
+	req := graphql.NewRequest(`mutation ($input: TokenInput!) {
+  createToken(input: $input) {
+    Token
+  }
+}`)
+	req.Var("input", map[string]interface{}{
+		"Name": name,
+		"Days": days,
+	})
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		CreateToken struct {
+			Token string
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		return "", err
+	}
+	return resp.CreateToken.Token, nil
+}
+
+// profile 个人资料，同一页面上有资料、密码、两步验证和令牌等多个表单，由 action 区分
+func profile(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+	// 按策略必须先完成两步验证设置时，使用暂存的令牌，只能进行设置相关的操作
+	enroll := token == ""
+	if enroll {
+		token = enrollToken(sess)
+	}
+
+	form := map[string]string{
+		"name":       strings.TrimSpace(c.FormValue("name")),
+		"phone":      strings.TrimSpace(c.FormValue("phone")),
+		"avatar":     c.FormValue("avatar_url"),
+		"token_name": strings.TrimSpace(c.FormValue("token_name")),
+		"expires":    c.FormValue("expires"),
+	}
+	action := c.FormValue("action")
+	errs := map[string]string{}
+	var message, created string
+	var codes []string // 新生成的恢复码，只显示一次
+	if c.Request().Method == http.MethodPost {
+		var err error
+		switch action {
+		case "profile":
+			err = saveProfile(token, c, form, errs)
+		case "password":
+			err = changePassword(token, c, errs)
+		case "token":
+			created, err = createToken(token, c, errs)
+		case "twofactor-setup":
+			var email string
+			if email, _, err = fetchAccount(token); err == nil {
+				err = setupTwoFactor(c, email)
+			}
+		case "twofactor-cancel":
+			err = cancelTwoFactor(c)
+		case "twofactor-enable":
+			codes, err = enableTwoFactor(token, c, errs)
+		case "twofactor-disable":
+			err = disableTwoFactor(token, c, errs)
+		case "recovery":
+			codes, err = regenerateRecoveryCodes(token, c, errs)
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest)
+		}
+		if err != nil {
+			log.Println(err)
+			message = err.Error()
+		} else if len(errs) == 0 && action != "token" && codes == nil {
+			return c.Redirect(http.StatusFound, "/profile.html?saved="+action)
+		}
+	}
+
+	req := graphql.NewRequest(`query index {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  profile: me {
+    Email
+    Phone
+    Roles
+    TwoFactor
+    RecoveryLeft
+    Tokens {
+      UUID
+      Name
+      CreateAt
+      ExpireAt
+      LastUsed
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me      viewer
+		Profile profileNode
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+
+	if action != "profile" {
+		form["name"] = resp.Me.Name
+		form["avatar"] = resp.Me.Avatar.URL
+		form["phone"] = ""
+		if resp.Profile.Phone != 0 {
+			form["phone"] = strconv.FormatUint(resp.Profile.Phone, 10)
+		}
+	}
+	if created != "" {
+		form["token_name"] = ""
+	}
+	if form["expires"] == "" {
+		form["expires"] = "90"
+	}
+
+	names := map[string]string{}
+	for _, r := range roles {
+		names[r.Key] = r.Name
+	}
+
+	// 正在设置两步验证时显示二维码和密钥
+	secret, _ := sess.Values["totp_secret"].(string)
+	if _, ok := sess.Values["token"]; ok {
+		enroll = false
+	}
+
+	return c.Render(http.StatusOK, "profile.html", map[string]interface{}{
+		"data":     resp,
+		"failures": failures(c),
+		"form":     form,
+		"roles":    names,
+		"expires":  tokenExpires,
+		"action":   action,
+		"saved":    c.QueryParam("saved"),
+		"created":  created,
+		"secret":   secret,
+		"codes":    codes,
+		"required": twoFactorRequired(resp.Profile.Roles),
+		"enroll":   enroll,
+		"errors":   errs,
+		"message":  message,
+	})
+}
+
+// revokeToken 吊销个人令牌，只能吊销自己的令牌，由后端校验
+func revokeToken(c echo.Context) error {
+	return mutate(c, "/profile.html", `mutation ($uuid: String!) {
+  revokeToken(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
@@ -0,0 +1,695 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+
+	"github.com/mia0x75/venus/g"
+	"github.com/mia0x75/venus/sqlfmt"
+)
+
+// readOnlyKeywords 在线查询允许执行的语句类型
+var readOnlyKeywords = map[string]bool{
+	"SELECT":   true,
+	"SHOW":     true,
+	"DESC":     true,
+	"DESCRIBE": true,
+	"EXPLAIN":  true,
+}
+
+// column 结果集的列定义
+type column struct {
+	Name string
+	Type string
+}
+
+// result 查询结果集的一页
+type result struct {
+	Total   uint
+	Columns []column
+	Rows    [][]*string
+}
+
+// pager 分页信息，供模板渲染分页条
+type pager struct {
+	Page  int
+	Size  int
+	Total int
+	Pages []int
+	Prev  int
+	Next  int
+	From  int
+	To    int
+}
+
+func newPager(page, size, total int) pager {
+	p := pager{Page: page, Size: size, Total: total}
+	last := (total + size - 1) / size
+	if last < 1 {
+		last = 1
+	}
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.Page > last {
+		p.Page = last
+	}
+	// 最多显示当前页前后各 2 页
+	for i := p.Page - 2; i <= p.Page+2; i++ {
+		if i >= 1 && i <= last {
+			p.Pages = append(p.Pages, i)
+		}
+	}
+	if p.Page > 1 {
+		p.Prev = p.Page - 1
+	}
+	if p.Page < last {
+		p.Next = p.Page + 1
+	}
+	if total > 0 {
+		p.From = (p.Page-1)*size + 1
+		p.To = p.Page * size
+		if p.To > total {
+			p.To = total
+		}
+	}
+	return p
+}
+
+// offset 返回当前页第一行在结果集中的位置
+func (p pager) offset() int {
+	return (p.Page - 1) * p.Size
+}
+
+// errExecutableComment MySQL 会执行 /*! ... */ 中的内容，/*+ ... */ 是优化器提示，都不允许出现
+var errExecutableComment = fmt.Errorf("不允许使用 /*! */ 或 /*+ */ 形式的注释")
+
+// statements 按分号拆分 SQL，忽略注释以及引号内的分号，返回去掉注释后的语句
+func statements(sql string) ([]string, error) {
+	var stmts []string
+	var buf strings.Builder
+	rs := []rune(sql)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			buf.WriteRune(r)
+			for i++; i < len(rs); i++ {
+				buf.WriteRune(rs[i])
+				if rs[i] == '\\' && r != '`' && i+1 < len(rs) {
+					i++
+					buf.WriteRune(rs[i])
+					continue
+				}
+				if rs[i] == r {
+					break
+				}
+			}
+		case r == '#' || (r == '-' && i+2 < len(rs) && rs[i+1] == '-' && unicode.IsSpace(rs[i+2])):
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+			buf.WriteRune(' ')
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			if i+2 < len(rs) && (rs[i+2] == '!' || rs[i+2] == '+') {
+				return nil, errExecutableComment
+			}
+			for i += 2; i+1 < len(rs) && !(rs[i] == '*' && rs[i+1] == '/'); i++ {
+			}
+			i++
+			buf.WriteRune(' ')
+		case r == ';':
+			if s := strings.TrimSpace(buf.String()); s != "" {
+				stmts = append(stmts, s)
+			}
+			buf.Reset()
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	if s := strings.TrimSpace(buf.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+	return stmts, nil
+}
+
+// topLevel 返回不在括号内的单词（转为大写）和逗号，每组括号记为一个 "("，引号内的内容作为一个单词
+func topLevel(stmt string) []string {
+	var tokens []string
+	rs := []rune(stmt)
+	depth := 0
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			start := i
+			for i++; i < len(rs) && rs[i] != r; i++ {
+				if rs[i] == '\\' && r != '`' {
+					i++
+				}
+			}
+			if depth == 0 {
+				end := i + 1
+				if end > len(rs) {
+					end = len(rs)
+				}
+				tokens = append(tokens, string(rs[start:end]))
+			}
+		case r == '(':
+			if depth == 0 {
+				tokens = append(tokens, "(")
+			}
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			tokens = append(tokens, ",")
+		case depth == 0 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'):
+			start := i
+			for i+1 < len(rs) && (unicode.IsLetter(rs[i+1]) || unicode.IsDigit(rs[i+1]) || rs[i+1] == '_' || rs[i+1] == '$') {
+				i++
+			}
+			tokens = append(tokens, strings.ToUpper(string(rs[start:i+1])))
+		}
+	}
+	return tokens
+}
+
+// mainKeyword 返回语句类型的关键字，WITH 开头时跳过公用表表达式，返回主语句的关键字
+func mainKeyword(stmt string) string {
+	stmt = strings.TrimLeft(stmt, "( \t\r\n")
+	tokens := topLevel(stmt)
+	if len(tokens) == 0 || tokens[0] != "WITH" {
+		if len(tokens) == 0 {
+			return ""
+		}
+		return tokens[0]
+	}
+
+	// WITH [RECURSIVE] name [(columns)] AS (subquery) [, ...] 主语句
+	i := 1
+	if i < len(tokens) && tokens[i] == "RECURSIVE" {
+		i++
+	}
+	for {
+		i++ // 名称
+		if i < len(tokens) && tokens[i] == "(" {
+			i++ // 列名列表
+		}
+		if i+1 >= len(tokens) || tokens[i] != "AS" || tokens[i+1] != "(" {
+			return ""
+		}
+		i += 2
+		if i < len(tokens) && tokens[i] == "," {
+			i++
+			continue
+		}
+		if i < len(tokens) {
+			return tokens[i]
+		}
+		return ""
+	}
+}
+
+// checkReadOnly 校验 SQL 为单条只读语句，返回去掉注释后的语句，调用方应该执行这条语句而不是原文，
+// 真正的权限控制仍由后端完成
+func checkReadOnly(sql string) (string, error) {
+	stmts, err := statements(sql)
+	if err != nil {
+		return "", err
+	}
+	if len(stmts) == 0 {
+		return "", fmt.Errorf("请输入要执行的 SQL 语句")
+	}
+	if len(stmts) > 1 {
+		return "", fmt.Errorf("一次只能执行一条 SQL 语句")
+	}
+	stmt := stmts[0]
+	keyword := mainKeyword(stmt)
+	if !readOnlyKeywords[keyword] {
+		return "", fmt.Errorf("只允许执行 SELECT、SHOW、DESC、EXPLAIN 语句")
+	}
+
+	// 按词法单元检查，引号内的内容不会误判，INTO 与后面的内容之间没有空格也能识别
+	tokens := significant(stmt)
+	for i, t := range tokens {
+		switch {
+		case word(t, "INTO"):
+			return "", fmt.Errorf("不允许使用 INTO 将查询结果写入文件或变量")
+		case word(t, "UPDATE"), word(t, "SHARE"):
+			if i > 0 && (word(tokens[i-1], "FOR") || word(tokens[i-1], "IN")) {
+				return "", fmt.Errorf("不允许在查询中加锁")
+			}
+		}
+	}
+
+	// EXPLAIN ANALYZE 会真正执行语句，被解释的语句本身也必须是只读的
+	if keyword == "EXPLAIN" || keyword == "DESC" || keyword == "DESCRIBE" {
+		explained, err := explainedStatement(stmt)
+		if err != nil {
+			return "", err
+		}
+		if explained != "" {
+			if _, err := checkReadOnly(explained); err != nil {
+				return "", err
+			}
+		}
+	}
+	return stmt, nil
+}
+
+// significant 去掉空白和注释之后的词法单元
+func significant(stmt string) []sqlfmt.Token {
+	var tokens []sqlfmt.Token
+	for _, t := range sqlfmt.Tokenize(stmt) {
+		if t.Kind != sqlfmt.Space && t.Kind != sqlfmt.Comment {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// word 词法单元是否为指定的单词，不区分大小写，字符串和带引号的标识符不算
+func word(t sqlfmt.Token, w string) bool {
+	switch t.Kind {
+	case sqlfmt.Keyword, sqlfmt.Identifier, sqlfmt.Function:
+		return strings.EqualFold(t.Text, w)
+	}
+	return false
+}
+
+// explainable EXPLAIN 可以解释的语句类型
+var explainable = []string{"SELECT", "WITH", "TABLE", "VALUES", "INSERT", "REPLACE", "UPDATE", "DELETE"}
+
+// explainedStatement 返回 EXPLAIN、DESC 后面被解释的语句，后面是表名时返回空字符串
+func explainedStatement(stmt string) (string, error) {
+	all := sqlfmt.Tokenize(stmt)
+	i := 0
+	skip := func() bool {
+		for i < len(all) && (all[i].Kind == sqlfmt.Space || all[i].Kind == sqlfmt.Comment) {
+			i++
+		}
+		return i < len(all)
+	}
+	skip()
+	i++ // EXPLAIN、DESC 或 DESCRIBE
+	for skip() {
+		t := all[i]
+		switch {
+		case word(t, "ANALYZE"), word(t, "EXTENDED"), word(t, "PARTITIONS"):
+			i++
+		case word(t, "FORMAT"):
+			// FORMAT = TRADITIONAL | JSON | TREE
+			i++
+			if skip() && all[i].Text == "=" {
+				i++
+			}
+			if skip() {
+				i++
+			}
+		case word(t, "FOR"):
+			return "", fmt.Errorf("不允许解释其他连接正在执行的语句")
+		case t.Text == "(":
+			return joinTokens(all[i:]), nil
+		default:
+			for _, w := range explainable {
+				if word(t, w) {
+					return joinTokens(all[i:]), nil
+				}
+			}
+			return "", nil // DESC 表名 [列名]
+		}
+	}
+	return "", nil
+}
+
+// joinTokens 把词法单元拼接回 SQL
+func joinTokens(tokens []sqlfmt.Token) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString(t.Text)
+	}
+	return b.String()
+}
+
+func createQuery(c echo.Context) error {
+	cfg := g.Config().Query
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	form := map[string]string{
+		"cluster":  c.FormValue("cluster"),
+		"database": c.FormValue("database"),
+		"content":  c.FormValue("content"),
+		"limit":    c.FormValue("limit"),
+	}
+
+	uuid := c.Param("uuid")
+	var message string
+	if c.Request().Method == http.MethodPost {
+		limit, _ := strconv.Atoi(form["limit"])
+		if limit <= 0 || limit > cfg.MaxRows {
+			limit = cfg.MaxRows
+		}
+		form["limit"] = strconv.Itoa(limit)
+
+		if stmt, err := checkReadOnly(form["content"]); err != nil {
+			message = err.Error()
+		} else if form["cluster"] == "" || form["database"] == "" {
+			message = "请选择目标群集和数据库"
+		} else {
+			req := graphql.NewRequest(`mutation ($input: QueryInput!) {
+  createQuery(input: $input) {
+    UUID
+  }
+}`)
+			req.Var("input", map[string]interface{}{
+				"ClusterUUID": form["cluster"],
+				"Database":    form["database"],
+				"Content":     stmt,
+				"Limit":       limit,
+				"Timeout":     cfg.Timeout,
+			})
+
+			// set header fields
+			req.Header.Set("Authentication", token)
+			req.Header.Set("Cache-Control", "no-cache")
+
+			var resp struct {
+				CreateQuery struct {
+					UUID string
+				}
+			}
+			if err := request(req, &resp); err != nil {
+				log.Println(err)
+				message = err.Error()
+			} else {
+				return c.Redirect(http.StatusFound, "/queries/"+resp.CreateQuery.UUID)
+			}
+		}
+	}
+
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	p := newPager(page, cfg.PageSize, cfg.MaxRows)
+
+	req := graphql.NewRequest(`query index ($uuid: String! $offset: Int! $limit: Int! $fetch: Boolean!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  clusters (first: 100){
+    edges {
+      node {
+        ...ClusterInfo
+        Databases
+      }
+    }
+  }
+  query (UUID: $uuid) @include(if: $fetch) {
+    UUID
+    Name
+    Starred
+    Content
+    Database
+    Status
+    Elapsed
+    Message
+    CreateAt
+    User {
+      ...UserInfo
+    }
+    Cluster {
+      ...ClusterInfo
+    }
+    Result (Offset: $offset, Limit: $limit) {
+      Total
+      Columns {
+        Name
+        Type
+      }
+      Rows
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+fragment ClusterInfo on Cluster {
+  UUID
+  Alias
+  Host
+  IP
+  Port
+}
+`)
+	req.Var("uuid", uuid)
+	req.Var("fetch", uuid != "")
+	req.Var("offset", p.offset())
+	req.Var("limit", p.Size)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me       viewer
+		Clusters struct {
+			Edges []struct {
+				Node struct {
+					UUID      string
+					Alias     string
+					Host      string
+					IP        string
+					Port      uint16
+					Databases []string
+				}
+			}
+		}
+		Query *struct {
+			UUID     string
+			Name     string
+			Starred  bool
+			Content  string
+			Database string
+			Status   uint8
+			Elapsed  float64 // 执行耗时，单位毫秒
+			Message  string
+			CreateAt uint
+			User     struct {
+				Name   string
+				UUID   string
+				Avatar struct {
+					URL string
+				}
+			}
+			Cluster struct {
+				UUID  string
+				Alias string
+				Host  string
+				IP    string
+				Port  uint16
+			}
+			Result result
+		}
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+
+	if q := resp.Query; q != nil {
+		p = newPager(p.Page, cfg.PageSize, int(q.Result.Total))
+		if c.Request().Method != http.MethodPost {
+			form["cluster"] = q.Cluster.UUID
+			form["database"] = q.Database
+			form["content"] = q.Content
+		}
+	}
+	if form["limit"] == "" {
+		form["limit"] = strconv.Itoa(cfg.MaxRows)
+	}
+
+	return c.Render(http.StatusOK, "create-query.html", map[string]interface{}{
+		"data":     resp,
+		"failures": failures(c),
+		"form":     form,
+		"pager":    p,
+		"message":  message,
+		"maxRows":  cfg.MaxRows,
+	})
+}
+
+func queries(c echo.Context) error {
+	req := graphql.NewRequest(`query index ($starred: Boolean!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  myQueries (first: 50, Starred: $starred){
+    edges {
+      node {
+        UUID
+        Name
+        Starred
+        Content
+        Database
+        Status
+        Elapsed
+        CreateAt
+        Cluster {
+          ...ClusterInfo
+        }
+      }
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+fragment ClusterInfo on Cluster {
+  UUID
+  Alias
+  Host
+  IP
+  Port
+}
+`)
+
+	tab := c.QueryParam("tab")
+	if tab != "starred" {
+		tab = "history"
+	}
+	req.Var("starred", tab == "starred")
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me        viewer
+		MyQueries struct {
+			Edges []struct {
+				Node struct {
+					UUID     string
+					Name     string
+					Starred  bool
+					Content  string
+					Database string
+					Status   uint8
+					Elapsed  float64
+					CreateAt uint
+					Cluster  struct {
+						UUID  string
+						Alias string
+						Host  string
+						IP    string
+						Port  uint16
+					}
+				}
+			}
+		}
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+
+	return c.Render(http.StatusOK, "queries-list.html", map[string]interface{}{
+		"data":     resp,
+		"failures": failures(c),
+		"tab":      tab,
+	})
+}
+
+// starQuery 收藏或取消收藏查询，收藏时可以为查询命名
+func starQuery(c echo.Context) error {
+	req := graphql.NewRequest(`mutation ($uuid: String! $input: QueryPatch!) {
+  updateQuery(UUID: $uuid, input: $input) {
+    UUID
+  }
+}`)
+	req.Var("uuid", c.Param("uuid"))
+	req.Var("input", map[string]interface{}{
+		"Name":    strings.TrimSpace(c.FormValue("name")),
+		"Starred": c.FormValue("starred") == "1",
+	})
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		UpdateQuery struct {
+			UUID string
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		fail(c, "操作失败: "+err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, back(c, "/queries/"+c.Param("uuid")))
+}
+
+// rerunQuery 使用原查询的群集、库和语句重新执行一次，结果作为一条新的查询记录
+func rerunQuery(c echo.Context) error {
+	req := graphql.NewRequest(`mutation ($uuid: String! $limit: Int! $timeout: Int!) {
+  rerunQuery(UUID: $uuid, Limit: $limit, Timeout: $timeout) {
+    UUID
+  }
+}`)
+	cfg := g.Config().Query
+	req.Var("uuid", c.Param("uuid"))
+	req.Var("limit", cfg.MaxRows)
+	req.Var("timeout", cfg.Timeout)
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		RerunQuery struct {
+			UUID string
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		fail(c, "重新执行失败: "+err.Error())
+		return c.Redirect(http.StatusFound, back(c, "/queries/"+c.Param("uuid")))
+	}
+
+	return c.Redirect(http.StatusFound, "/queries/"+resp.RerunQuery.UUID)
+}
@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{"", nil},
+		{" ; ;", nil},
+		{"SELECT 1", []string{"SELECT 1"}},
+		{"SELECT 1; SELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"SELECT ';' AS a", []string{"SELECT ';' AS a"}},
+		{`SELECT "a;b", 'it\'s;'`, []string{`SELECT "a;b", 'it\'s;'`}},
+		{"SELECT `a;b` FROM t", []string{"SELECT `a;b` FROM t"}},
+		{"SELECT 1 -- ; DROP TABLE t\n", []string{"SELECT 1"}},
+		{"SELECT 1 # ; DROP TABLE t", []string{"SELECT 1"}},
+		{"SELECT /* ; */ 1", []string{"SELECT   1"}},
+		{"SELECT 1--2", []string{"SELECT 1--2"}},
+	}
+	for _, tt := range tests {
+		got, err := statements(tt.sql)
+		if err != nil {
+			t.Errorf("statements(%q): %v", tt.sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("statements(%q)=%q，应为 %q", tt.sql, got, tt.want)
+		}
+	}
+
+	for _, sql := range []string{
+		"SELECT /*! 1 */",
+		"SELECT /*+ MAX_EXECUTION_TIME(1) */ 1",
+		"/*!50000 DROP TABLE t */",
+	} {
+		if _, err := statements(sql); err != errExecutableComment {
+			t.Errorf("statements(%q) 应返回 errExecutableComment，实际为 %v", sql, err)
+		}
+	}
+}
+
+func TestMainKeyword(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want string
+	}{
+		{"select 1", "SELECT"},
+		{"(SELECT 1) UNION (SELECT 2)", "SELECT"},
+		{"WITH a AS (SELECT 1) SELECT * FROM a", "SELECT"},
+		{"WITH RECURSIVE a (n) AS (SELECT 1), b AS (SELECT 2) SELECT * FROM a, b", "SELECT"},
+		{"WITH a AS (SELECT 1) DELETE FROM t", "DELETE"},
+		{"WITH a AS (SELECT 1)", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := mainKeyword(tt.stmt); got != tt.want {
+			t.Errorf("mainKeyword(%q)=%q，应为 %q", tt.stmt, got, tt.want)
+		}
+	}
+}
+
+func TestCheckReadOnly(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string // 为空表示应当拒绝
+	}{
+		{"SELECT 1;", "SELECT 1"},
+		{"show tables", "show tables"},
+		{"DESC t", "DESC t"},
+		{"DESCRIBE t c", "DESCRIBE t c"},
+		{"EXPLAIN SELECT * FROM t", "EXPLAIN SELECT * FROM t"},
+		{"EXPLAIN FORMAT=JSON SELECT 1", "EXPLAIN FORMAT=JSON SELECT 1"},
+		{"EXPLAIN ANALYZE SELECT 1", "EXPLAIN ANALYZE SELECT 1"},
+		{"SELECT 'INTO OUTFILE' AS a", "SELECT 'INTO OUTFILE' AS a"},
+		{"SELECT `into` FROM t", "SELECT `into` FROM t"},
+		{"SELECT 1 FOR UPDATE", ""},
+		{"SELECT 1 LOCK IN SHARE MODE", ""},
+		{"SELECT 1 FOR SHARE", ""},
+		{"SELECT * FROM t INTO OUTFILE '/tmp/x'", ""},
+		{"SELECT * FROM t INTO OUTFILE'/tmp/x'", ""},
+		{"SELECT * FROM t INTO/*x*/OUTFILE '/tmp/x'", ""},
+		{"SELECT * FROM t INTO DUMPFILE '/tmp/x'", ""},
+		{"SELECT 1 INTO @a", ""},
+		{"SELECT 1; DELETE FROM t", ""},
+		{"SELECT ';'; DELETE FROM t", ""},
+		{"DELETE FROM t", ""},
+		{"WITH a AS (SELECT 1) DELETE FROM t", ""},
+		{"EXPLAIN ANALYZE DELETE FROM t", ""},
+		{"EXPLAIN ANALYZE UPDATE t SET a = 1", ""},
+		{"EXPLAIN FORMAT = TREE INSERT INTO t VALUES (1)", ""},
+		{"DESC DELETE FROM t", ""},
+		{"EXPLAIN FOR CONNECTION 1", ""},
+		{"SELECT /*! SLEEP(1) */ 1", ""},
+		{"-- 只有注释\n", ""},
+	}
+	for _, tt := range tests {
+		got, err := checkReadOnly(tt.sql)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("checkReadOnly(%q) 应当拒绝", tt.sql)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("checkReadOnly(%q): %v", tt.sql, err)
+		} else if got != tt.want {
+			t.Errorf("checkReadOnly(%q)=%q，应为 %q", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+)
+
+// 权限，由后端根据用户的角色汇总后通过 me.Permissions 返回
+const (
+	permUsers    = "users.manage"    // 管理用户
+	permRules    = "rules.manage"    // 管理审核规则
+	permOptions  = "options.manage"  // 修改系统选项
+	permClusters = "clusters.manage" // 新建、修改、停用和删除群集
+	permTasks    = "tasks.manage"    // 重试和取消其他人的任务
+	permTaskView = "tasks.view"      // 查看任务队列的实时状态
+	permCrons    = "crons.manage"    // 新建、修改、暂停、恢复和删除预约
+	permTickets  = "tickets.create"  // 提交工单
+	permQueries  = "queries.execute" // 在线查询以及导出查询结果
+	permAnalyze  = "queries.analyze" // 查询重写和执行计划分析
+)
+
+// allow 声明访问路由需要的权限，拥有其中任意一个即可，否则返回 403，
+// 通过校验后当前用户保存在上下文中，处理函数只需要用户标识和权限时用 current 读取，
+// 渲染页面需要的角标等信息仍然随页面数据一起查询
+func allow(permissions ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			sess, _ := session.Get("session", c)
+			token, _ := sess.Values["token"].(string)
+
+			me, err := fetchViewer(token)
+			if err != nil {
+				log.Println(err)
+			}
+			for _, p := range permissions {
+				if me.Can(p) {
+					c.Set("viewer", me)
+					return next(c)
+				}
+			}
+			return echo.NewHTTPError(http.StatusForbidden)
+		}
+	}
+}
+
+// current 返回 allow 保存在上下文中的当前用户
+func current(c echo.Context) viewer {
+	me, _ := c.Get("viewer").(viewer)
+	return me
+}
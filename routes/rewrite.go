@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+
+	"github.com/mia0x75/venus/sqlfmt"
+)
+
+func rewriteQuery(c echo.Context) error {
+	form := map[string]string{
+		"cluster":  c.FormValue("cluster"),
+		"database": c.FormValue("database"),
+		"content":  c.FormValue("content"),
+	}
+	post := c.Request().Method == http.MethodPost
+
+	// 只有提交了 SQL 时才调用改写接口
+	req := graphql.NewRequest(`query index ($input: RewriteInput! $rewrite: Boolean!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  clusters (first: 100){
+    edges {
+      node {
+        ...ClusterInfo
+        Databases
+      }
+    }
+  }
+  rewrite (input: $input) @include(if: $rewrite) {
+    Content
+    Rules {
+      Name
+      Summary
+      Description
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+fragment ClusterInfo on Cluster {
+  UUID
+  Alias
+  Host
+  IP
+  Port
+}
+`)
+	var message string
+	if post && strings.TrimSpace(form["content"]) == "" {
+		message = "请输入要改写的 SQL 语句"
+	}
+	req.Var("rewrite", post && message == "")
+	req.Var("input", map[string]interface{}{
+		"ClusterUUID": form["cluster"],
+		"Database":    form["database"],
+		"Content":     form["content"],
+	})
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me       viewer
+		Clusters struct {
+			Edges []struct {
+				Node struct {
+					UUID      string
+					Alias     string
+					Host      string
+					IP        string
+					Port      uint16
+					Databases []string
+				}
+			}
+		}
+		Rewrite *struct {
+			Content string
+			Rules   []struct {
+				Name        string
+				Summary     string
+				Description string
+			}
+		}
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		message = err.Error()
+	}
+
+	var lines []diffLine
+	if resp.Rewrite != nil {
+		// 两边都先格式化，只显示改写带来的差异，不显示排版上的差异
+		lines = diff(splitLines(sqlfmt.Format(form["content"])), splitLines(sqlfmt.Format(resp.Rewrite.Content)))
+	}
+
+	return c.Render(http.StatusOK, "rewrite-query.html", map[string]interface{}{
+		"data":    resp,
+		"form":    form,
+		"diff":    lines,
+		"message": message,
+	})
+}
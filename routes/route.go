@@ -12,8 +12,12 @@ func Routes(e *echo.Echo) {
 	p := e.Group("/") // 公开组
 	p.GET("about.html", about)
 	p.Any("login.html", login)
-	p.GET("register.html", register)
-	p.GET("forgot-password.html", forgot)
+	p.Any("register.html", register)
+	p.Any("verify-email.html", verifyEmail)
+	p.Any("forgot-password.html", forgot)
+	p.Any("reset-password.html", recoverPassword)
+	p.Any("two-factor.html", twoFactor)
+	p.GET("captcha/:id", captchaImage)
 	p.GET("400.html", error400)
 	p.GET("401.html", error401)
 	p.GET("402.html", error402)
@@ -30,26 +34,71 @@ func Routes(e *echo.Echo) {
 				sess.Save(c.Request(), c.Response())
 				return next(c)
 			}
+			// 还没有完成按策略要求的两步验证设置，只能访问个人资料页
+			if enrollToken(sess) != "" {
+				if !enrollAllowed(c.Path(), c.FormValue("action")) {
+					return c.Redirect(http.StatusFound, "/profile.html#two-factor")
+				}
+				return next(c)
+			}
 
 			return c.Redirect(http.StatusFound, "/login.html")
 		}
 	})
 
 	r.GET("index.html", dashboard)
-	r.GET("users-list.html", users)
+	r.Any("profile.html", profile)
+	r.POST("tokens/:uuid/revoke", revokeToken)
+	r.GET("two-factor/qrcode.png", twoFactorQRCode)
+	r.GET("users-list.html", users, allow(permUsers))
+	r.Any("create-user.html", editUser, allow(permUsers))
+	r.Any("users/:uuid/edit", editUser, allow(permUsers))
+	r.POST("users/:uuid/enable", enableUser, allow(permUsers))
+	r.POST("users/:uuid/disable", disableUser, allow(permUsers))
+	r.POST("users/:uuid/reset-password", resetPassword, allow(permUsers))
+	r.POST("users/:uuid/delete", removeUser, allow(permUsers))
 	r.GET("crypto-currencies.html", currencies)
 	r.GET("pagination.html", pagination)
 	r.GET("lookup.html", lookup)
 	r.GET("invoice.html", invoice)
 	r.GET("sample-cards.html", sample)
 	r.GET("clusters-list.html", clusters)
+	r.Any("create-cluster.html", editCluster, allow(permClusters))
+	r.GET("clusters/:uuid", cluster)
+	r.Any("clusters/:uuid/edit", editCluster, allow(permClusters))
+	r.POST("clusters/:uuid/enable", enableCluster, allow(permClusters))
+	r.POST("clusters/:uuid/disable", disableCluster, allow(permClusters))
+	r.POST("clusters/:uuid/delete", removeCluster, allow(permClusters))
+	r.GET("schema.html", schemas)
+	r.GET("schema-diff.html", schemaDiff)
+	r.POST("schema-diff.html", schemaDiff, allow(permTickets))
 	r.GET("crons-list.html", crons)
-	r.GET("options-list.html", options)
+	r.Any("create-cron.html", editCron, allow(permCrons))
+	r.Any("crons/:uuid/edit", editCron, allow(permCrons))
+	r.POST("crons/:uuid/pause", pauseCron, allow(permCrons))
+	r.POST("crons/:uuid/resume", resumeCron, allow(permCrons))
+	r.POST("crons/:uuid/delete", removeCron, allow(permCrons))
+	r.Any("options-list.html", options, allow(permOptions))
 	r.GET("queries-list.html", queries)
-	r.GET("rules-list.html", rules)
+	r.Any("create-query.html", createQuery, allow(permQueries))
+	r.GET("queries/:uuid", createQuery, allow(permQueries))
+	r.POST("queries/:uuid/star", starQuery, allow(permQueries))
+	r.POST("queries/:uuid/rerun", rerunQuery, allow(permQueries))
+	r.GET("queries/:uuid/export", exportQuery, allow(permQueries))
+	r.Any("rewrite-query.html", rewriteQuery, allow(permAnalyze))
+	r.Any("analyze-query.html", analyzeQuery, allow(permAnalyze))
+	r.GET("rules-list.html", rules, allow(permRules))
+	r.Any("rules-test.html", testRules, allow(permRules))
+	r.Any("rules/:uuid/edit", editRule, allow(permRules))
+	r.POST("rules/:uuid/enable", enableRule, allow(permRules))
+	r.POST("rules/:uuid/disable", disableRule, allow(permRules))
+	r.POST("rules/:uuid/reset", resetRule, allow(permRules))
 	r.GET("tasks-list.html", tasks)
+	r.GET("tasks/events", taskEvents, allow(permTaskView, permTasks))
+	r.GET("tasks/:uuid", task)
+	r.POST("tasks/:uuid/retry", retryTask, allow(permTaskView, permTasks))
+	r.POST("tasks/:uuid/cancel", cancelTask, allow(permTaskView, permTasks))
 	r.GET("tickets-list.html", tickets)
-	r.GET("users-list.html", users)
-
-	// e.GET("/captcha", captcha.Server(captcha.StdWidth, captcha.StdHeight))
+	r.GET("user-tickets.html", userTickets)
+	r.GET("tickets/:uuid", ticket)
 }
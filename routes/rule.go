@@ -0,0 +1,389 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+)
+
+// 规则的标志位
+const (
+	ruleEnabled  = 1 << iota // 启用
+	ruleEditable             // 阈值可以修改
+	ruleOptional             // 可以停用，必须遵守的规则不允许停用
+)
+
+// ruleGroups 规则分组的名称
+var ruleGroups = map[uint8]string{
+	1:  "创建数据库",
+	2:  "修改数据库",
+	3:  "删除数据库",
+	4:  "创建表",
+	5:  "修改表",
+	6:  "重命名表",
+	7:  "删除表",
+	8:  "创建索引",
+	9:  "删除索引",
+	10: "创建视图",
+	11: "删除视图",
+	12: "插入数据",
+	13: "替换数据",
+	14: "更新数据",
+	15: "删除数据",
+	16: "查询数据",
+}
+
+// ruleNode 审核规则，Element 表示 Values 的类型，决定编辑时使用的输入框
+type ruleNode struct {
+	UUID        string
+	Name        string
+	Group       uint8
+	Description string
+	VldrGroup   uint16
+	Values      string
+	Bitwise     uint8
+	Func        string
+	Element     string // int 整数，list 逗号分隔的列表，regexp 正则表达式，其他为文本，为空表示没有阈值
+	CreateAt    uint
+	UpdateAt    uint
+	Default     struct { // 出厂设置
+		Values  string
+		Bitwise uint8
+	}
+}
+
+// ruleFields 与 ruleNode 对应的查询字段
+const ruleFields = `
+    UUID
+    Name
+    Group
+    Description
+    VldrGroup
+    Values
+    Bitwise
+    Func
+    Element
+    CreateAt
+    UpdateAt
+    Default {
+      Values
+      Bitwise
+    }`
+
+// Enabled 规则是否启用
+func (r ruleNode) Enabled() bool {
+	return r.Bitwise&ruleEnabled != 0
+}
+
+// Editable 阈值是否可以修改
+func (r ruleNode) Editable() bool {
+	return r.Element != "" && r.Bitwise&ruleEditable != 0
+}
+
+// Optional 规则是否可以停用
+func (r ruleNode) Optional() bool {
+	return r.Bitwise&ruleOptional != 0
+}
+
+// Changed 阈值或启用状态与出厂设置不同
+func (r ruleNode) Changed() bool {
+	return r.Values != r.Default.Values || (r.Bitwise^r.Default.Bitwise)&ruleEnabled != 0
+}
+
+// ruleSection 同一分组的规则
+type ruleSection struct {
+	Group   uint8
+	Name    string
+	Rules   []ruleNode
+	Changed int // 与出厂设置不同的规则数
+}
+
+// groupRules 按分组整理规则，分组按编号排序
+func groupRules(rules []ruleNode) []*ruleSection {
+	groups := map[uint8]*ruleSection{}
+	var sections []*ruleSection
+	for _, r := range rules {
+		s, ok := groups[r.Group]
+		if !ok {
+			name, ok := ruleGroups[r.Group]
+			if !ok {
+				name = fmt.Sprintf("分组 %d", r.Group)
+			}
+			s = &ruleSection{Group: r.Group, Name: name}
+			groups[r.Group] = s
+			sections = append(sections, s)
+		}
+		s.Rules = append(s.Rules, r)
+		if r.Changed() {
+			s.Changed++
+		}
+	}
+	sort.Slice(sections, func(i, j int) bool { return sections[i].Group < sections[j].Group })
+	return sections
+}
+
+// checkRuleValue 按规则的类型校验并规范化输入的阈值
+func checkRuleValue(element, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch element {
+	case "int":
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("请输入非负整数")
+		}
+		return strconv.FormatUint(n, 10), nil
+	case "list":
+		var items []string
+		for _, v := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+			if v = strings.TrimSpace(v); v != "" {
+				items = append(items, v)
+			}
+		}
+		return strings.Join(items, ","), nil
+	case "regexp":
+		if _, err := regexp.Compile(value); err != nil {
+			return "", fmt.Errorf("无效的正则表达式: %v", err)
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+// rules 按分组列出审核规则，可以只看某个分组或与出厂设置不同的规则
+func rules(c echo.Context) error {
+	req := graphql.NewRequest(`query index {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  rules {` + ruleFields + `
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me    viewer
+		Rules []ruleNode
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+
+	group, _ := strconv.ParseUint(c.QueryParam("group"), 10, 8)
+	changed := c.QueryParam("changed") == "1"
+
+	sections := groupRules(resp.Rules)
+	var shown []*ruleSection
+	total := 0
+	for _, s := range sections {
+		total += s.Changed
+		if group != 0 && uint64(s.Group) != group {
+			continue
+		}
+		if changed {
+			if s.Changed == 0 {
+				continue
+			}
+			f := *s
+			f.Rules = nil
+			for _, r := range s.Rules {
+				if r.Changed() {
+					f.Rules = append(f.Rules, r)
+				}
+			}
+			s = &f
+		}
+		shown = append(shown, s)
+	}
+
+	return c.Render(http.StatusOK, "rules-list.html", map[string]interface{}{
+		"data":     resp,
+		"failures": failures(c),
+		"sections": sections,
+		"shown":    shown,
+		"group":    uint8(group),
+		"changed":  changed,
+		"total":    total,
+	})
+}
+
+// editRule 修改规则的阈值
+func editRule(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	uuid := c.Param("uuid")
+	req := graphql.NewRequest(`query index ($uuid: String!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  rule (UUID: $uuid) {` + ruleFields + `
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+	req.Var("uuid", uuid)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me   viewer
+		Rule *ruleNode
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+	if resp.Rule == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	rule := resp.Rule
+	next := fmt.Sprintf("/rules-list.html?group=%d", rule.Group)
+
+	value := rule.Values
+	var message string
+	if c.Request().Method == http.MethodPost {
+		value = c.FormValue("values")
+		if !rule.Editable() {
+			return echo.NewHTTPError(http.StatusForbidden)
+		}
+		if v, err := checkRuleValue(rule.Element, value); err != nil {
+			message = err.Error()
+		} else {
+			req := graphql.NewRequest(`mutation ($uuid: String! $input: RuleInput!) {
+  updateRule(UUID: $uuid, input: $input) {
+    UUID
+  }
+}`)
+			req.Var("uuid", uuid)
+			req.Var("input", map[string]interface{}{
+				"Values": v,
+			})
+
+			// set header fields
+			req.Header.Set("Authentication", token)
+			req.Header.Set("Cache-Control", "no-cache")
+
+			var resp struct{}
+			if err := request(req, &resp); err != nil {
+				log.Println(err)
+				message = err.Error()
+			} else {
+				return c.Redirect(http.StatusFound, next)
+			}
+		}
+	} else if rule.Element == "list" {
+		// 列表每行显示一项
+		value = strings.Replace(value, ",", "\n", -1)
+	}
+
+	return c.Render(http.StatusOK, "rule.html", map[string]interface{}{
+		"data":     resp,
+		"failures": failures(c),
+		"group":    ruleGroups[rule.Group],
+		"value":    value,
+		"next":     next,
+		"message":  message,
+	})
+}
+
+// enableRule 启用规则
+func enableRule(c echo.Context) error {
+	return mutate(c, "/rules-list.html", `mutation ($uuid: String!) {
+  enableRule(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
+
+// fetchRule 读取单条规则，用于变更前的校验
+func fetchRule(token, uuid string) (*ruleNode, error) {
+	req := graphql.NewRequest(`query ($uuid: String!) {
+  rule (UUID: $uuid) {` + ruleFields + `
+  }
+}`)
+	req.Var("uuid", uuid)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Rule *ruleNode
+	}
+	err := request(req, &resp)
+	return resp.Rule, err
+}
+
+// disableRule 停用规则，只有可选的规则才能停用
+func disableRule(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	rule, err := fetchRule(token, c.Param("uuid"))
+	if err != nil {
+		log.Println(err)
+	}
+	if rule == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	if !rule.Optional() {
+		return echo.NewHTTPError(http.StatusForbidden)
+	}
+
+	return mutate(c, "/rules-list.html", `mutation ($uuid: String!) {
+  disableRule(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
+
+// resetRule 恢复规则的出厂设置
+func resetRule(c echo.Context) error {
+	return mutate(c, "/rules-list.html", `mutation ($uuid: String!) {
+  resetRule(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
@@ -0,0 +1,300 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+)
+
+// ruleOverride 草稿中对一条规则的修改，未列出的规则保持当前设置
+type ruleOverride struct {
+	UUID    string
+	Values  string
+	Enabled bool
+}
+
+// violation 语句触发的规则
+type violation struct {
+	Rule struct {
+		UUID  string
+		Name  string
+		Group uint8
+	}
+	Level   string // error 或 warning
+	Message string
+	Change  string // 对比草稿时，+ 表示只在草稿下触发，- 表示只在当前规则下触发
+}
+
+// statementResult 一条语句的审核结果
+type statementResult struct {
+	No         int // 序号，从 1 开始
+	Content    string
+	Violations []violation
+}
+
+// Fired 实际触发的规则数，不含草稿下不再触发的规则
+func (s statementResult) Fired() int {
+	n := 0
+	for _, v := range s.Violations {
+		if v.Change != "-" {
+			n++
+		}
+	}
+	return n
+}
+
+// validateFields 与 statementResult 对应的查询字段
+const validateFields = `
+    Statements {
+      Content
+      Violations {
+        Rule {
+          UUID
+          Name
+          Group
+        }
+        Level
+        Message
+      }
+    }`
+
+// compareResults 对比当前规则和草稿的审核结果，两次审核的是同一段 SQL，语句一一对应
+func compareResults(current, draft []statementResult) []statementResult {
+	results := make([]statementResult, len(draft))
+	for i, s := range draft {
+		fired := map[string]bool{}
+		var before []violation
+		if i < len(current) {
+			before = current[i].Violations
+		}
+		for _, v := range before {
+			fired[v.Rule.UUID] = true
+		}
+
+		results[i].Content = s.Content
+		kept := map[string]bool{}
+		for _, v := range s.Violations {
+			if !fired[v.Rule.UUID] {
+				v.Change = "+"
+			}
+			kept[v.Rule.UUID] = true
+			results[i].Violations = append(results[i].Violations, v)
+		}
+		for _, v := range before {
+			if !kept[v.Rule.UUID] {
+				v.Change = "-"
+				results[i].Violations = append(results[i].Violations, v)
+			}
+		}
+	}
+	return results
+}
+
+// parseDrafts 读取表单中的草稿，阈值按规则的类型校验；
+// 从规则编辑页试运行时，rule 和 values 是正在编辑的规则及其阈值
+func parseDrafts(c echo.Context, rules []ruleNode) ([]ruleOverride, error) {
+	index := map[string]ruleNode{}
+	for _, r := range rules {
+		index[r.UUID] = r
+	}
+
+	form, _ := c.FormParams()
+	uuids, values, enabled := form["draft_rule"], form["draft_values"], form["draft_enabled"]
+	if uuid := c.FormValue("rule"); uuid != "" {
+		uuids, values, enabled = []string{uuid}, []string{c.FormValue("values")}, nil
+		if r, ok := index[uuid]; ok && r.Enabled() {
+			enabled = []string{"1"}
+		} else {
+			enabled = []string{"0"}
+		}
+	}
+
+	var drafts []ruleOverride
+	seen := map[string]bool{}
+	for i, uuid := range uuids {
+		r, ok := index[uuid]
+		if !ok || seen[uuid] {
+			continue
+		}
+		seen[uuid] = true
+		d := ruleOverride{UUID: uuid, Values: r.Values, Enabled: r.Enabled()}
+		if i < len(values) && r.Editable() {
+			v, err := checkRuleValue(r.Element, values[i])
+			if err != nil {
+				return drafts, fmt.Errorf("规则「%s」: %v", r.Name, err)
+			}
+			d.Values = v
+		}
+		if i < len(enabled) && r.Optional() {
+			d.Enabled = enabled[i] == "1"
+		}
+		drafts = append(drafts, d)
+	}
+	return drafts, nil
+}
+
+// testRules 规则测试台，用当前规则或草稿审核输入的 SQL，查看每条语句触发了哪些规则，
+// 使用草稿时同时审核两次，标出修改带来的差异
+func testRules(c echo.Context) error {
+	form := map[string]string{
+		"cluster":  c.FormValue("cluster"),
+		"database": c.FormValue("database"),
+		"content":  c.FormValue("content"),
+		"ruleset":  c.FormValue("ruleset"),
+	}
+	if c.FormValue("rule") != "" {
+		form["ruleset"] = "draft"
+	}
+	if form["ruleset"] != "draft" {
+		form["ruleset"] = "current"
+	}
+
+	req := graphql.NewRequest(`query index {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  clusters (first: 100){
+    edges {
+      node {
+        ...ClusterInfo
+        Databases
+      }
+    }
+  }
+  rules {` + ruleFields + `
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+fragment ClusterInfo on Cluster {
+  UUID
+  Alias
+  Host
+  IP
+  Port
+}
+`)
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me       viewer
+		Clusters struct {
+			Edges []struct {
+				Node struct {
+					UUID      string
+					Alias     string
+					Host      string
+					IP        string
+					Port      uint16
+					Databases []string
+				}
+			}
+		}
+		Rules []ruleNode
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+
+	var message string
+	drafts, err := parseDrafts(c, resp.Rules)
+	if err != nil {
+		message = err.Error()
+	}
+
+	var results []statementResult
+	tested := false
+	// 从规则编辑页跳转过来时只带入草稿，不立即审核
+	if c.Request().Method == http.MethodPost && c.FormValue("rule") == "" && message == "" {
+		if strings.TrimSpace(form["content"]) == "" {
+			message = "请输入要审核的 SQL 语句"
+		} else {
+			results, err = validate(token, form, drafts)
+			if err != nil {
+				log.Println(err)
+				message = err.Error()
+			} else {
+				tested = true
+			}
+		}
+	}
+
+	return c.Render(http.StatusOK, "rules-test.html", map[string]interface{}{
+		"data":     resp,
+		"form":     form,
+		"sections": groupRules(resp.Rules),
+		"drafts":   append(drafts, ruleOverride{Enabled: true}), // 末尾留一个空行用于添加草稿
+		"results":  results,
+		"tested":   tested,
+		"message":  message,
+	})
+}
+
+// validate 调用后端的审核接口，使用草稿时同时用当前规则审核并对比
+func validate(token string, form map[string]string, drafts []ruleOverride) ([]statementResult, error) {
+	draft := form["ruleset"] == "draft"
+	req := graphql.NewRequest(`query ($current: ValidateInput! $draft: ValidateInput! $compare: Boolean!) {
+  current: validate (input: $current) {` + validateFields + `
+  }
+  draft: validate (input: $draft) @include(if: $compare) {` + validateFields + `
+  }
+}`)
+	input := map[string]interface{}{
+		"ClusterUUID": form["cluster"],
+		"Database":    form["database"],
+		"Content":     form["content"],
+	}
+	req.Var("current", input)
+	withDrafts := map[string]interface{}{"Rules": drafts}
+	for k, v := range input {
+		withDrafts[k] = v
+	}
+	req.Var("draft", withDrafts)
+	req.Var("compare", draft)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Current struct {
+			Statements []statementResult
+		}
+		Draft *struct {
+			Statements []statementResult
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		return nil, err
+	}
+	results := resp.Current.Statements
+	if resp.Draft != nil {
+		results = compareResults(resp.Current.Statements, resp.Draft.Statements)
+	}
+	for i := range results {
+		results[i].No = i + 1
+	}
+	return results, nil
+}
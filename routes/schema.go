@@ -0,0 +1,197 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+)
+
+// schemaColumn 表中的一列
+type schemaColumn struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Default  *string // 没有默认值时为空
+	Extra    string  // 如 auto_increment
+	Comment  string
+
+	expression string // 生成列的表达式，从建表语句中解析
+}
+
+// schemaIndex 表上的一个索引，主键的名字为 PRIMARY
+type schemaIndex struct {
+	Name    string
+	Columns []string
+	Unique  bool
+
+	create string // 建表语句中的完整定义，包含索引类型和前缀长度
+}
+
+// schemaTable 表结构
+type schemaTable struct {
+	Name    string
+	Engine  string
+	Rows    uint64 // 估算的行数
+	Comment string
+	Columns []schemaColumn
+	Indexes []schemaIndex
+	Create  string // SHOW CREATE TABLE 的输出
+
+	foreignKeys bool // 是否定义了外键，从建表语句中解析
+}
+
+// tableFields 与 schemaTable 对应的查询字段
+const tableFields = `
+  Name
+  Engine
+  Rows
+  Comment
+  Columns {
+    Name
+    Type
+    Nullable
+    Default
+    Extra
+    Comment
+  }
+  Indexes {
+    Name
+    Columns
+    Unique
+  }
+  Create`
+
+// tableMatch 搜索命中的表，以及表中命中的列
+type tableMatch struct {
+	Name    string
+	Comment string
+	Columns []string
+}
+
+// searchTables 按表名和列名搜索，关键字为空时返回全部表
+func searchTables(tables []schemaTable, q string) []tableMatch {
+	q = strings.ToLower(strings.TrimSpace(q))
+	var matches []tableMatch
+	for _, t := range tables {
+		m := tableMatch{Name: t.Name, Comment: t.Comment}
+		if q != "" {
+			for _, col := range t.Columns {
+				if strings.Contains(strings.ToLower(col.Name), q) {
+					m.Columns = append(m.Columns, col.Name)
+				}
+			}
+			if !strings.Contains(strings.ToLower(t.Name), q) && len(m.Columns) == 0 {
+				continue
+			}
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+// schemas 表结构浏览，选择群集和库后列出全部表，点击表名查看列、索引和建表语句
+func schemas(c echo.Context) error {
+	form := map[string]string{
+		"cluster":  c.QueryParam("cluster"),
+		"database": c.QueryParam("database"),
+		"table":    c.QueryParam("table"),
+		"q":        c.QueryParam("q"),
+	}
+
+	req := graphql.NewRequest(`query index ($cluster: String! $database: String! $table: String! $fetch: Boolean! $detail: Boolean!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  clusters (first: 100){
+    edges {
+      node {
+        ...ClusterInfo
+        Databases
+      }
+    }
+  }
+  schema (ClusterUUID: $cluster, Database: $database) @include(if: $fetch) {
+    Tables {
+      Name
+      Comment
+      Columns {
+        Name
+      }
+    }
+  }
+  table (ClusterUUID: $cluster, Database: $database, Name: $table) @include(if: $detail) {` + tableFields + `
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+fragment ClusterInfo on Cluster {
+  UUID
+  Alias
+  Host
+  IP
+  Port
+}
+`)
+	fetch := form["cluster"] != "" && form["database"] != ""
+	req.Var("cluster", form["cluster"])
+	req.Var("database", form["database"])
+	req.Var("table", form["table"])
+	req.Var("fetch", fetch)
+	req.Var("detail", fetch && form["table"] != "")
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me       viewer
+		Clusters struct {
+			Edges []struct {
+				Node struct {
+					UUID      string
+					Alias     string
+					Host      string
+					IP        string
+					Port      uint16
+					Databases []string
+				}
+			}
+		}
+		Schema *struct {
+			Tables []schemaTable
+		}
+		Table *schemaTable
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+
+	var tables []tableMatch
+	if resp.Schema != nil {
+		tables = searchTables(resp.Schema.Tables, form["q"])
+	}
+
+	return c.Render(http.StatusOK, "schema.html", map[string]interface{}{
+		"data":   resp,
+		"form":   form,
+		"tables": tables,
+	})
+}
@@ -0,0 +1,521 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+)
+
+// columnChange 列的变化，Op 为 + 新增，- 删除，~ 修改
+type columnChange struct {
+	Op       string
+	Name     string
+	From, To *schemaColumn
+	Moved    bool // 位置不同
+}
+
+// indexChange 索引的变化，Op 的含义同 columnChange
+type indexChange struct {
+	Op       string
+	Name     string
+	From, To *schemaIndex
+}
+
+// tableDiff 一张表的差异，Op 为 + 需要新建，- 仅存在于目标库，~ 结构不同
+type tableDiff struct {
+	Op        string
+	Name      string
+	Columns   []columnChange
+	Indexes   []indexChange
+	Statement string // 使目标表与源表一致的语句，为空表示无需变更
+}
+
+// literal 默认值中不需要加引号的部分
+var literal = regexp.MustCompile(`(?i)^(NULL|-?[0-9]+(\.[0-9]+)?|CURRENT_TIMESTAMP(\([0-6]?\))?|NOW\(\))$`)
+
+// SHOW CREATE TABLE 输出中的列定义行、索引定义行，以及生成列的表达式
+var (
+	columnLine    = regexp.MustCompile("^  `((?:[^`]|``)+)` ")
+	indexLine     = regexp.MustCompile("^  (PRIMARY |UNIQUE |FULLTEXT |SPATIAL )?KEY (`((?:[^`]|``)+)` )?\\(")
+	generatedExpr = regexp.MustCompile(` GENERATED ALWAYS AS \((.*)\) (VIRTUAL|STORED)`)
+	foreignKey    = regexp.MustCompile("^  CONSTRAINT `(?:[^`]|``)+` FOREIGN KEY ")
+	autoIncrement = regexp.MustCompile(` AUTO_INCREMENT=[0-9]+`)
+)
+
+// parseCreate 从建表语句中补充 information_schema 没有提供的信息：生成列的表达式，
+// 以及索引的完整定义，FULLTEXT、SPATIAL 等类型和前缀长度都只能从这里得到
+func (t *schemaTable) parseCreate() {
+	columns := map[string]*schemaColumn{}
+	for i := range t.Columns {
+		columns[t.Columns[i].Name] = &t.Columns[i]
+	}
+	indexes := map[string]*schemaIndex{}
+	for i := range t.Indexes {
+		indexes[t.Indexes[i].Name] = &t.Indexes[i]
+	}
+	for _, line := range strings.Split(t.Create, "\n") {
+		line = strings.TrimRight(line, ",")
+		if m := indexLine.FindStringSubmatch(line); m != nil {
+			name := strings.Replace(m[3], "``", "`", -1)
+			if m[1] == "PRIMARY " {
+				name = "PRIMARY"
+			}
+			if idx, ok := indexes[name]; ok {
+				idx.create = strings.TrimSpace(line)
+			}
+		} else if foreignKey.MatchString(line) {
+			t.foreignKeys = true
+		} else if m := columnLine.FindStringSubmatch(line); m != nil {
+			if col, ok := columns[strings.Replace(m[1], "``", "`", -1)]; ok {
+				if g := generatedExpr.FindStringSubmatch(line); g != nil {
+					col.expression = g[1]
+				}
+			}
+		}
+	}
+}
+
+func quote(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func quoteString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `''`).Replace(s) + "'"
+}
+
+// definition 生成列定义。EXTRA 中的 DEFAULT_GENERATED 只说明默认值是表达式，
+// VIRTUAL GENERATED、STORED GENERATED 需要换成 GENERATED ALWAYS AS (...) 的写法
+func (col *schemaColumn) definition() string {
+	def := []string{quote(col.Name), col.Type}
+	extra := col.Extra
+	expression := strings.Contains(extra, "DEFAULT_GENERATED")
+	extra = strings.TrimSpace(strings.Replace(extra, "DEFAULT_GENERATED", "", -1))
+	generated := ""
+	for _, kind := range []string{"VIRTUAL", "STORED"} {
+		if strings.Contains(extra, kind+" GENERATED") {
+			generated = kind
+			extra = strings.TrimSpace(strings.Replace(extra, kind+" GENERATED", "", -1))
+		}
+	}
+	if generated != "" {
+		def = append(def, fmt.Sprintf("GENERATED ALWAYS AS (%s) %s", col.expression, generated))
+	}
+	if col.Nullable {
+		def = append(def, "NULL")
+	} else {
+		def = append(def, "NOT NULL")
+	}
+	if d := col.Default; d != nil && generated == "" {
+		switch {
+		case literal.MatchString(*d):
+			def = append(def, "DEFAULT "+*d)
+		case expression:
+			def = append(def, "DEFAULT ("+*d+")")
+		default:
+			def = append(def, "DEFAULT "+quoteString(*d))
+		}
+	}
+	if extra != "" {
+		def = append(def, extra)
+	}
+	if col.Comment != "" {
+		def = append(def, "COMMENT "+quoteString(col.Comment))
+	}
+	return strings.Join(def, " ")
+}
+
+func (col *schemaColumn) equal(o *schemaColumn) bool {
+	return col.definition() == o.definition()
+}
+
+// definition 生成索引定义，优先使用建表语句中的原始定义
+func (idx *schemaIndex) definition() string {
+	if idx.create != "" {
+		return idx.create
+	}
+	cols := make([]string, len(idx.Columns))
+	for i, c := range idx.Columns {
+		cols[i] = quote(c)
+	}
+	switch {
+	case idx.Name == "PRIMARY":
+		return fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(cols, ", "))
+	case idx.Unique:
+		return fmt.Sprintf("UNIQUE KEY %s (%s)", quote(idx.Name), strings.Join(cols, ", "))
+	default:
+		return fmt.Sprintf("KEY %s (%s)", quote(idx.Name), strings.Join(cols, ", "))
+	}
+}
+
+func (idx *schemaIndex) drop() string {
+	if idx.Name == "PRIMARY" {
+		return "DROP PRIMARY KEY"
+	}
+	return "DROP INDEX " + quote(idx.Name)
+}
+
+// diffTable 比较两张同名表，生成使目标表与源表一致的 ALTER TABLE 语句，
+// drop 为 false 时不删除仅存在于目标表中的列
+func diffTable(source, target *schemaTable, drop bool) tableDiff {
+	d := tableDiff{Op: "~", Name: source.Name}
+	var drops, columns, adds []string
+
+	old := map[string]*schemaColumn{}
+	for i := range target.Columns {
+		old[target.Columns[i].Name] = &target.Columns[i]
+	}
+	wanted := map[string]bool{}
+	for _, col := range source.Columns {
+		wanted[col.Name] = true
+	}
+	// order 模拟执行过程中目标表的列顺序，用来判断已有的列是否需要调整位置
+	var order []string
+	for _, col := range target.Columns {
+		if wanted[col.Name] || !drop {
+			order = append(order, col.Name)
+		}
+	}
+	prev := ""
+	for i := range source.Columns {
+		col := &source.Columns[i]
+		position := " FIRST"
+		if prev != "" {
+			position = " AFTER " + quote(prev)
+		}
+		if o, ok := old[col.Name]; !ok {
+			d.Columns = append(d.Columns, columnChange{Op: "+", Name: col.Name, To: col})
+			columns = append(columns, "ADD COLUMN "+col.definition()+position)
+			order = moveAfter(order, col.Name, prev)
+		} else {
+			// 只调整了位置的列同样需要 MODIFY，否则列的顺序永远不会同步
+			moved := predecessor(order, col.Name, wanted) != prev
+			if moved {
+				order = moveAfter(order, col.Name, prev)
+			} else {
+				position = ""
+			}
+			if moved || !col.equal(o) {
+				d.Columns = append(d.Columns, columnChange{Op: "~", Name: col.Name, From: o, To: col, Moved: moved})
+				columns = append(columns, "MODIFY COLUMN "+col.definition()+position)
+			}
+		}
+		prev = col.Name
+		delete(old, col.Name)
+	}
+	var first []string
+	for i := range target.Columns {
+		col := &target.Columns[i]
+		if _, ok := old[col.Name]; ok {
+			d.Columns = append(d.Columns, columnChange{Op: "-", Name: col.Name, From: col})
+			if drop {
+				columns = append(columns, "DROP COLUMN "+quote(col.Name))
+			} else if autoIncrementColumn(col) && !leading(source.Indexes, col.Name) {
+				// 保留的自增列必须是某个索引的第一列，源表中没有这样的索引时先去掉自增属性，
+				// 否则 DROP PRIMARY KEY 会被 MySQL 拒绝
+				kept := *col
+				kept.Extra = strings.TrimSpace(strings.Replace(kept.Extra, "auto_increment", "", -1))
+				first = append(first, "MODIFY COLUMN "+kept.definition())
+			}
+		}
+	}
+
+	indexes := map[string]*schemaIndex{}
+	for i := range target.Indexes {
+		indexes[target.Indexes[i].Name] = &target.Indexes[i]
+	}
+	for i := range source.Indexes {
+		idx := &source.Indexes[i]
+		if o, ok := indexes[idx.Name]; !ok {
+			d.Indexes = append(d.Indexes, indexChange{Op: "+", Name: idx.Name, To: idx})
+			adds = append(adds, "ADD "+idx.definition())
+		} else if o.definition() != idx.definition() {
+			d.Indexes = append(d.Indexes, indexChange{Op: "~", Name: idx.Name, From: o, To: idx})
+			drops = append(drops, o.drop())
+			adds = append(adds, "ADD "+idx.definition())
+		}
+		delete(indexes, idx.Name)
+	}
+	for i := range target.Indexes {
+		idx := &target.Indexes[i]
+		if _, ok := indexes[idx.Name]; ok {
+			d.Indexes = append(d.Indexes, indexChange{Op: "-", Name: idx.Name, From: idx})
+			drops = append(drops, idx.drop())
+		}
+	}
+
+	// 先删除索引，再变更列，最后添加索引，避免索引引用的列不存在
+	clauses := append(append(append(first, drops...), columns...), adds...)
+	if len(clauses) > 0 {
+		d.Statement = fmt.Sprintf("ALTER TABLE %s\n  %s;", quote(source.Name), strings.Join(clauses, ",\n  "))
+	}
+	return d
+}
+
+// predecessor 返回 name 前面第一个在 wanted 中的列，没有时返回空字符串
+func predecessor(order []string, name string, wanted map[string]bool) string {
+	prev := ""
+	for _, n := range order {
+		if n == name {
+			return prev
+		}
+		if wanted[n] {
+			prev = n
+		}
+	}
+	return prev
+}
+
+// moveAfter 把 name 移到 after 的后面，after 为空时移到最前面
+func moveAfter(order []string, name, after string) []string {
+	moved := make([]string, 0, len(order)+1)
+	if after == "" {
+		moved = append(moved, name)
+	}
+	for _, n := range order {
+		if n == name {
+			continue
+		}
+		moved = append(moved, n)
+		if n == after {
+			moved = append(moved, name)
+		}
+	}
+	return moved
+}
+
+// autoIncrementColumn 是否为自增列
+func autoIncrementColumn(col *schemaColumn) bool {
+	return strings.Contains(col.Extra, "auto_increment")
+}
+
+// leading 是否有索引以 name 为第一列
+func leading(indexes []schemaIndex, name string) bool {
+	for _, idx := range indexes {
+		if len(idx.Columns) > 0 && idx.Columns[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// diffSchemas 比较源库和目标库，只返回有差异的表，外键不参与比较，另外返回包含外键的表名
+func diffSchemas(source, target []schemaTable, drop bool) ([]tableDiff, []string) {
+	found := map[string]bool{}
+	var foreign []string
+	for _, tables := range [][]schemaTable{source, target} {
+		for i := range tables {
+			tables[i].parseCreate()
+			if tables[i].foreignKeys && !found[tables[i].Name] {
+				found[tables[i].Name] = true
+				foreign = append(foreign, tables[i].Name)
+			}
+		}
+	}
+	sort.Strings(foreign)
+
+	old := map[string]*schemaTable{}
+	for i := range target {
+		old[target[i].Name] = &target[i]
+	}
+
+	var diffs []tableDiff
+	for i := range source {
+		t := &source[i]
+		if o, ok := old[t.Name]; !ok {
+			// 自增的当前值属于数据而不是结构，不带到目标库
+			create := autoIncrement.ReplaceAllString(t.Create, "")
+			d := tableDiff{Op: "+", Name: t.Name, Statement: strings.TrimRight(create, "; \n") + ";"}
+			for j := range t.Columns {
+				d.Columns = append(d.Columns, columnChange{Op: "+", Name: t.Columns[j].Name, To: &t.Columns[j]})
+			}
+			diffs = append(diffs, d)
+		} else if d := diffTable(t, o, drop); len(d.Columns) > 0 || len(d.Indexes) > 0 {
+			diffs = append(diffs, d)
+		}
+		delete(old, t.Name)
+	}
+	for _, t := range old {
+		d := tableDiff{Op: "-", Name: t.Name}
+		if drop {
+			d.Statement = "DROP TABLE " + quote(t.Name) + ";"
+		}
+		diffs = append(diffs, d)
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs, foreign
+}
+
+// schemaDiff 比较两个库的表结构并生成变更语句，提交时以目标库为对象创建工单
+func schemaDiff(c echo.Context) error {
+	form := map[string]string{
+		"source_cluster":  c.FormValue("source_cluster"),
+		"source_database": c.FormValue("source_database"),
+		"target_cluster":  c.FormValue("target_cluster"),
+		"target_database": c.FormValue("target_database"),
+		"drop":            c.FormValue("drop"),
+		"subject":         strings.TrimSpace(c.FormValue("subject")),
+	}
+	fetch := form["source_cluster"] != "" && form["source_database"] != "" &&
+		form["target_cluster"] != "" && form["target_database"] != ""
+
+	req := graphql.NewRequest(`query index ($sc: String! $sd: String! $tc: String! $td: String! $fetch: Boolean!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  clusters (first: 100){
+    edges {
+      node {
+        ...ClusterInfo
+        Databases
+      }
+    }
+  }
+  source: schema (ClusterUUID: $sc, Database: $sd) @include(if: $fetch) {
+    Tables {
+      ...TableInfo
+    }
+  }
+  target: schema (ClusterUUID: $tc, Database: $td) @include(if: $fetch) {
+    Tables {
+      ...TableInfo
+    }
+  }
+}
+fragment TableInfo on Table {` + tableFields + `
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+fragment ClusterInfo on Cluster {
+  UUID
+  Alias
+  Host
+  IP
+  Port
+}
+`)
+	req.Var("sc", form["source_cluster"])
+	req.Var("sd", form["source_database"])
+	req.Var("tc", form["target_cluster"])
+	req.Var("td", form["target_database"])
+	req.Var("fetch", fetch)
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me       viewer
+		Clusters struct {
+			Edges []struct {
+				Node struct {
+					UUID      string
+					Alias     string
+					Host      string
+					IP        string
+					Port      uint16
+					Databases []string
+				}
+			}
+		}
+		Source *struct {
+			Tables []schemaTable
+		}
+		Target *struct {
+			Tables []schemaTable
+		}
+	}
+
+	var message string
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		if fetch {
+			message = "读取表结构失败: " + err.Error()
+		}
+	}
+
+	var diffs []tableDiff
+	var foreign, statements []string
+	fetched := resp.Source != nil && resp.Target != nil
+	if fetched {
+		diffs, foreign = diffSchemas(resp.Source.Tables, resp.Target.Tables, form["drop"] == "1")
+		for _, d := range diffs {
+			if d.Statement != "" {
+				statements = append(statements, d.Statement)
+			}
+		}
+	}
+	script := strings.Join(statements, "\n\n")
+
+	if c.Request().Method == http.MethodPost && message == "" {
+		switch {
+		case !fetched:
+			message = "请选择源库和目标库"
+		case script == "":
+			message = "两个库的表结构一致，无需变更"
+		case form["subject"] == "":
+			message = "请填写工单主题"
+		default:
+			req := graphql.NewRequest(`mutation ($input: TicketInput!) {
+  createTicket(input: $input) {
+    UUID
+  }
+}`)
+			req.Var("input", map[string]interface{}{
+				"Subject":     form["subject"],
+				"ClusterUUID": form["target_cluster"],
+				"Database":    form["target_database"],
+				"Content":     script,
+			})
+
+			// set header fields
+			req.Header.Set("Authentication", token)
+			req.Header.Set("Cache-Control", "no-cache")
+
+			var resp struct {
+				CreateTicket struct {
+					UUID string
+				}
+			}
+			if err := request(req, &resp); err != nil {
+				log.Println(err)
+				message = err.Error()
+			} else {
+				return c.Redirect(http.StatusFound, "/tickets/"+resp.CreateTicket.UUID)
+			}
+		}
+	}
+	if form["subject"] == "" && fetch {
+		form["subject"] = fmt.Sprintf("同步 %s 的表结构到 %s", form["source_database"], form["target_database"])
+	}
+
+	return c.Render(http.StatusOK, "schema-diff.html", map[string]interface{}{
+		"data":    resp,
+		"form":    form,
+		"fetched": fetched,
+		"diffs":   diffs,
+		"foreign": foreign,
+		"script":  script,
+		"message": message,
+	})
+}
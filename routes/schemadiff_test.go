@@ -0,0 +1,84 @@
+package routes
+
+import "testing"
+
+func intColumns(names ...string) []schemaColumn {
+	var columns []schemaColumn
+	for _, n := range names {
+		columns = append(columns, schemaColumn{Name: n, Type: "int"})
+	}
+	return columns
+}
+
+func TestDiffTable(t *testing.T) {
+	id := schemaColumn{Name: "id", Type: "int", Extra: "auto_increment"}
+	primary := func(column string) []schemaIndex {
+		return []schemaIndex{{Name: "PRIMARY", Columns: []string{column}, Unique: true}}
+	}
+	tests := []struct {
+		name           string
+		source, target schemaTable
+		drop           bool
+		want           string
+	}{
+		{
+			"一致",
+			schemaTable{Columns: intColumns("a", "b")},
+			schemaTable{Columns: intColumns("a", "b")},
+			false,
+			"",
+		},
+		{
+			"只调整了位置",
+			schemaTable{Columns: intColumns("a", "b", "c")},
+			schemaTable{Columns: intColumns("a", "c", "b")},
+			false,
+			"ALTER TABLE `t`\n  MODIFY COLUMN `b` int NOT NULL AFTER `a`;",
+		},
+		{
+			"新增列",
+			schemaTable{Columns: intColumns("a", "b", "c")},
+			schemaTable{Columns: intColumns("b", "c")},
+			false,
+			"ALTER TABLE `t`\n  ADD COLUMN `a` int NOT NULL FIRST;",
+		},
+		{
+			"目标库多出的列不影响位置",
+			schemaTable{Columns: intColumns("a", "b")},
+			schemaTable{Columns: intColumns("x", "a", "y", "b")},
+			false,
+			"",
+		},
+		{
+			"保留的自增列先去掉自增属性",
+			schemaTable{Columns: intColumns("a"), Indexes: primary("a")},
+			schemaTable{Columns: append([]schemaColumn{id}, intColumns("a")...), Indexes: primary("id")},
+			false,
+			"ALTER TABLE `t`\n  MODIFY COLUMN `id` int NOT NULL,\n  DROP PRIMARY KEY,\n  ADD PRIMARY KEY (`a`);",
+		},
+		{
+			"删除自增列",
+			schemaTable{Columns: intColumns("a"), Indexes: primary("a")},
+			schemaTable{Columns: append([]schemaColumn{id}, intColumns("a")...), Indexes: primary("id")},
+			true,
+			"ALTER TABLE `t`\n  DROP PRIMARY KEY,\n  DROP COLUMN `id`,\n  ADD PRIMARY KEY (`a`);",
+		},
+	}
+	for _, tt := range tests {
+		tt.source.Name, tt.target.Name = "t", "t"
+		if got := diffTable(&tt.source, &tt.target, tt.drop).Statement; got != tt.want {
+			t.Errorf("%s:\n%s\n应为\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestForeignKeys(t *testing.T) {
+	create := "CREATE TABLE `c` (\n  `id` int NOT NULL,\n  `p` int DEFAULT NULL,\n  PRIMARY KEY (`id`),\n" +
+		"  CONSTRAINT `fk_p` FOREIGN KEY (`p`) REFERENCES `p` (`id`)\n) ENGINE=InnoDB"
+	source := []schemaTable{{Name: "c", Create: create}, {Name: "p"}}
+	target := []schemaTable{{Name: "c", Create: create}, {Name: "p"}}
+	_, foreign := diffSchemas(source, target, false)
+	if len(foreign) != 1 || foreign[0] != "c" {
+		t.Errorf("包含外键的表为 %v，应为 [c]", foreign)
+	}
+}
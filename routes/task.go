@@ -0,0 +1,372 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+)
+
+// taskPollInterval 推送任务状态时轮询后端的间隔
+const taskPollInterval = 3 * time.Second
+
+// taskStates 任务状态，顺序即页面上统计卡片的顺序
+var taskStates = []string{"pending", "running", "failed", "done"}
+
+// taskNode 任务队列中的一个任务
+type taskNode struct {
+	UUID     string
+	Name     string
+	Status   string
+	Progress float64 // 执行进度，0-100
+	Message  string
+	Attempts uint
+	UpdateAt uint
+	CreateAt uint
+	User     struct {
+		Name   string
+		UUID   string
+		Avatar struct {
+			URL string
+		}
+	}
+}
+
+// taskQueue 任务列表及各状态的任务数
+type taskQueue struct {
+	Me         viewer
+	Statistics []struct {
+		Group string
+		Key   string
+		Value float64
+	}
+	Tasks struct {
+		Edges []struct {
+			Node taskNode
+		}
+	}
+}
+
+// counts 按状态汇总任务数
+func (q *taskQueue) counts() map[string]uint {
+	counts := map[string]uint{}
+	for _, s := range taskStates {
+		counts[s] = 0
+	}
+	for _, s := range q.Statistics {
+		if s.Group == "tasks" {
+			counts[s.Key] = uint(s.Value)
+		}
+	}
+	return counts
+}
+
+// fetchTasks 读取任务队列
+func fetchTasks(token string) (*taskQueue, error) {
+	req := graphql.NewRequest(`query index {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  statistics (Groups: ["tasks"]) {
+    Group
+    Key
+    Value
+  }
+  tasks (first: 100) {
+    edges {
+      node {
+        UUID
+        Name
+        Status
+        Progress
+        Message
+        Attempts
+        UpdateAt
+        CreateAt
+        User {
+          ...UserInfo
+        }
+      }
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp := &taskQueue{}
+	err := request(req, resp)
+	return resp, err
+}
+
+func tasks(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	resp, err := fetchTasks(token)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return c.Render(http.StatusOK, "tasks-list.html", map[string]interface{}{
+		"data":   resp,
+		"counts": resp.counts(),
+		"states": taskStates,
+	})
+}
+
+// taskEvents 以 Server-Sent Events 推送任务状态的变化，
+// 后端没有订阅接口，这里定时轮询并只推送有变化的任务
+func taskEvents(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// 禁止 nginx 缓冲
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, v interface{}) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return err
+		}
+		w.Flush()
+		return nil
+	}
+
+	// 页面渲染之后到连接建立之前状态可能已经变化，断线重连时也会错过中间的变化，
+	// 所以连接后先推送一次完整的状态，之后只推送有变化的部分
+	var last map[string]uint
+	seen := map[string]taskNode{}
+	first := true
+
+	ticker := time.NewTicker(taskPollInterval)
+	defer ticker.Stop()
+	for {
+		resp, err := fetchTasks(token)
+		if err != nil {
+			log.Println(err)
+		} else {
+			counts := resp.counts()
+			if first || fmt.Sprint(counts) != fmt.Sprint(last) {
+				if err := send("stats", counts); err != nil {
+					return nil
+				}
+			}
+			last = counts
+
+			// 列表按创建时间倒序，页面把新任务插到最前面，所以从旧到新推送，
+			// seen 只保留本次返回的任务，移出列表的任务不再占用内存
+			current := make(map[string]taskNode, len(resp.Tasks.Edges))
+			for i := len(resp.Tasks.Edges) - 1; i >= 0; i-- {
+				t := resp.Tasks.Edges[i].Node
+				if old, ok := seen[t.UUID]; !ok || old != t {
+					if err := send("task", t); err != nil {
+						return nil
+					}
+				}
+				current[t.UUID] = t
+			}
+			seen = current
+			first = false
+		}
+
+		// 注释行用作心跳，及时发现已经断开的连接
+		if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+			return nil
+		}
+		w.Flush()
+
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// taskDetail 任务详情，包含完整的错误输出和每次执行的记录
+type taskDetail struct {
+	taskNode
+	Params string
+	Error  string
+	Runs   []struct {
+		Attempt  uint
+		Status   string
+		Error    string
+		StartAt  uint
+		FinishAt uint
+	}
+}
+
+// canRetry 只有失败的任务可以重试
+func (t *taskDetail) canRetry(me viewer) bool {
+	return t.Status == "failed" && t.owned(me)
+}
+
+// canCancel 只有尚未完成的任务可以取消
+func (t *taskDetail) canCancel(me viewer) bool {
+	return (t.Status == "pending" || t.Status == "running") && t.owned(me)
+}
+
+// owned 拥有任务管理权限的用户可以操作所有任务，其他人只能操作自己发起的任务
+func (t *taskDetail) owned(me viewer) bool {
+	return me.Can(permTasks) || (me.UUID != "" && me.UUID == t.User.UUID)
+}
+
+// fetchTask 读取单个任务，任务不存在时返回 nil
+func fetchTask(token, uuid string) (viewer, *taskDetail, error) {
+	req := graphql.NewRequest(`query index ($uuid: String!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  task (UUID: $uuid) {
+    UUID
+    Name
+    Status
+    Progress
+    Message
+    Attempts
+    Params
+    Error
+    UpdateAt
+    CreateAt
+    User {
+      ...UserInfo
+    }
+    Runs {
+      Attempt
+      Status
+      Error
+      StartAt
+      FinishAt
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+	req.Var("uuid", uuid)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me   viewer
+		Task *taskDetail
+	}
+	err := request(req, &resp)
+	return resp.Me, resp.Task, err
+}
+
+func task(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	me, t, err := fetchTask(token, c.Param("uuid"))
+	if err != nil {
+		log.Println(err)
+	}
+	if t == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	return c.Render(http.StatusOK, "task.html", map[string]interface{}{
+		"data": map[string]interface{}{
+			"Me":   me,
+			"Task": t,
+		},
+		"canRetry":  t.canRetry(me),
+		"canCancel": t.canCancel(me),
+		"failures":  failures(c),
+	})
+}
+
+// retryTask 重新执行失败的任务
+func retryTask(c echo.Context) error {
+	return mutateTask(c, (*taskDetail).canRetry, `mutation ($uuid: String!) {
+  retryTask(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
+
+// cancelTask 取消等待中或执行中的任务
+func cancelTask(c echo.Context) error {
+	return mutateTask(c, (*taskDetail).canCancel, `mutation ($uuid: String!) {
+  cancelTask(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
+
+// mutateTask 校验当前用户可以操作任务后执行变更，后端同样会做权限校验，
+// 路由已经要求任务权限，这里只需校验任务的状态和归属
+func mutateTask(c echo.Context, allowed func(*taskDetail, viewer) bool, mutation string) error {
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	uuid := c.Param("uuid")
+	_, t, err := fetchTask(token, uuid)
+	if err != nil {
+		log.Println(err)
+	}
+	if t == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+	if !allowed(t, current(c)) {
+		return echo.NewHTTPError(http.StatusForbidden)
+	}
+
+	req := graphql.NewRequest(mutation)
+	req.Var("uuid", uuid)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct{}
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		fail(c, "操作失败: "+err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/tasks/"+uuid)
+}
@@ -0,0 +1,333 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+
+	"github.com/mia0x75/venus/qrcode"
+	"github.com/mia0x75/venus/totp"
+)
+
+// 两步验证的发行方，显示在身份验证器应用中
+const issuer = "Venus"
+
+// pendingTTL 输入密码后完成第二步验证的时限
+const pendingTTL = 5 * time.Minute
+
+// enrollTTL 按策略必须启用两步验证时，登录后完成设置的时限
+const enrollTTL = 30 * time.Minute
+
+// 第二步验证的尝试次数限制，按账号计数，重新输入密码不会清零
+var twoFactorAttempts = newLimiter(5, pendingTTL)
+
+// twoFactorRoles 必须启用两步验证的角色，由系统选项 two_factor_roles 控制，多个角色以逗号分隔
+func twoFactorRoles() []string {
+	v, err := publicOption("two_factor_roles")
+	if err != nil {
+		log.Println(err)
+	}
+	var roles []string
+	for _, r := range strings.Split(v, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
+// twoFactorRequired 拥有的角色中是否有要求启用两步验证的
+func twoFactorRequired(roles []string) bool {
+	for _, required := range twoFactorRoles() {
+		for _, r := range roles {
+			if r == required {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// enrollToken 按策略必须启用两步验证但还没有启用时，登录令牌暂存在 enrolling 中，
+// 只能用于个人资料页完成设置，设置完成后才换成正式的会话
+func enrollToken(sess *sessions.Session) string {
+	token, _ := sess.Values["enrolling"].(string)
+	at, _ := sess.Values["enrolling_at"].(int64)
+	if token == "" || time.Since(time.Unix(at, 0)) > enrollTTL {
+		return ""
+	}
+	return token
+}
+
+// enrollAllowed 完成设置之前可以访问的页面和可以提交的操作
+func enrollAllowed(path, action string) bool {
+	switch path {
+	case "/two-factor/qrcode.png":
+		return true
+	case "/profile.html":
+		switch action {
+		case "", "twofactor-setup", "twofactor-cancel", "twofactor-enable":
+			return true
+		}
+	}
+	return false
+}
+
+// fetchAccount 当前用户的邮箱和角色
+func fetchAccount(token string) (string, []string, error) {
+	req := graphql.NewRequest(`query {
+  me {
+    Email
+    Roles
+  }
+}`)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me struct {
+			Email string
+			Roles []string
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		return "", nil, err
+	}
+	return resp.Me.Email, resp.Me.Roles, nil
+}
+
+// twoFactor 登录的第二步，输入身份验证器应用中的验证码或者一个恢复码
+func twoFactor(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	pending, _ := sess.Values["pending"].(string)
+	at, _ := sess.Values["pending_at"].(int64)
+	// 登录时记下的账号，第二步的失败次数计入同一个账号的登录失败次数
+	account, _ := sess.Values["pending_account"].(string)
+	uuid, _ := sess.Values["pending_uuid"].(string)
+	abandon := func() {
+		delete(sess.Values, "pending")
+		delete(sess.Values, "pending_at")
+		delete(sess.Values, "pending_account")
+		delete(sess.Values, "pending_uuid")
+		sess.Save(c.Request(), c.Response())
+	}
+	if pending == "" || uuid == "" || time.Since(time.Unix(at, 0)) > pendingTTL {
+		abandon()
+		return c.Redirect(http.StatusFound, "/login.html")
+	}
+
+	recovery := c.FormValue("recovery") == "1"
+	data := map[string]interface{}{
+		"recovery": recovery,
+	}
+	if c.Request().Method != http.MethodPost {
+		return c.Render(http.StatusOK, "two-factor.html", data)
+	}
+
+	code := strings.TrimSpace(c.FormValue("code"))
+	if code == "" {
+		data["message"] = "请输入验证码"
+		return c.Render(http.StatusOK, "two-factor.html", data)
+	}
+	// 尝试次数过多或者账号已被锁定时作废本次登录，需要重新输入密码
+	if locked, _ := loginByAccount.locked(account); locked || !twoFactorAttempts.allow(uuid) {
+		abandon()
+		return c.Render(http.StatusOK, "login.html", map[string]interface{}{
+			"message": "验证码错误次数过多，请重新登录",
+			"open":    registrationOpen(),
+		})
+	}
+
+	req := graphql.NewRequest(`mutation ($code: String! $recovery: Boolean!) {
+  verifyTwoFactor(Code: $code, Recovery: $recovery) {
+    Token
+    RecoveryLeft
+  }
+}`)
+	req.Var("code", code)
+	req.Var("recovery", recovery)
+
+	// set header fields
+	req.Header.Set("Authentication", pending)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		VerifyTwoFactor struct {
+			Token        string
+			RecoveryLeft int
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		loginByAccount.hit(account)
+		data["message"] = "验证码错误"
+		return c.Render(http.StatusOK, "two-factor.html", data)
+	}
+
+	// 两步都通过才算登录成功，清除失败记录
+	loginByAccount.reset(account)
+	twoFactorAttempts.reset(uuid)
+	sess.Values["token"] = resp.VerifyTwoFactor.Token
+	abandon()
+	// 恢复码快用完时提醒重新生成
+	if recovery && resp.VerifyTwoFactor.RecoveryLeft <= 2 {
+		return c.Redirect(http.StatusFound, "/profile.html?saved=recovery-low#two-factor")
+	}
+	return c.Redirect(http.StatusFound, "/index.html")
+}
+
+// setupTwoFactor 生成新的密钥，保存在会话中，验证通过后才提交给后端
+func setupTwoFactor(c echo.Context, account string) error {
+	secret, err := totp.Secret()
+	if err != nil {
+		return err
+	}
+	sess, _ := session.Get("session", c)
+	sess.Values["totp_secret"] = secret
+	sess.Values["totp_account"] = account
+	return sess.Save(c.Request(), c.Response())
+}
+
+// cancelTwoFactor 放弃正在进行的设置
+func cancelTwoFactor(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	delete(sess.Values, "totp_secret")
+	delete(sess.Values, "totp_account")
+	return sess.Save(c.Request(), c.Response())
+}
+
+// enableTwoFactor 校验扫码后输入的验证码并启用两步验证，返回恢复码
+func enableTwoFactor(token string, c echo.Context, errs map[string]string) ([]string, error) {
+	sess, _ := session.Get("session", c)
+	secret, _ := sess.Values["totp_secret"].(string)
+	code := c.FormValue("code")
+	if secret == "" {
+		errs["code"] = "设置已过期，请重新开始"
+		return nil, nil
+	}
+	if !totp.Validate(secret, code, time.Now()) {
+		errs["code"] = "验证码错误，请确认手机时间准确后重试"
+		return nil, nil
+	}
+
+	req := graphql.NewRequest(`mutation ($secret: String! $code: String!) {
+  enableTwoFactor(Secret: $secret, Code: $code) {
+    RecoveryCodes
+  }
+}`)
+	req.Var("secret", secret)
+	req.Var("code", code)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		EnableTwoFactor struct {
+			RecoveryCodes []string
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		return nil, err
+	}
+
+	delete(sess.Values, "totp_secret")
+	delete(sess.Values, "totp_account")
+	// 按策略要求设置的，完成后换成正式的会话，正式会话中原有的 token 不能保留
+	sess.Values["token"] = token
+	delete(sess.Values, "enrolling")
+	delete(sess.Values, "enrolling_at")
+	sess.Save(c.Request(), c.Response())
+	return resp.EnableTwoFactor.RecoveryCodes, nil
+}
+
+// disableTwoFactor 停用两步验证，需要输入当前的验证码，角色要求启用时不允许停用
+func disableTwoFactor(token string, c echo.Context, errs map[string]string) error {
+	_, roles, err := fetchAccount(token)
+	if err != nil {
+		return err
+	}
+	code := strings.TrimSpace(c.FormValue("code"))
+	switch {
+	case twoFactorRequired(roles):
+		errs["code"] = "你的角色要求启用两步验证，不能停用"
+	case code == "":
+		errs["code"] = "请输入验证码"
+	}
+	if len(errs) > 0 {
+		return nil
+	}
+
+	req := graphql.NewRequest(`mutation ($code: String!) {
+  disableTwoFactor(Code: $code) {
+    UUID
+  }
+}`)
+	req.Var("code", code)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct{}
+	return request(req, &resp)
+}
+
+// regenerateRecoveryCodes 重新生成恢复码，之前的恢复码全部作废
+func regenerateRecoveryCodes(token string, c echo.Context, errs map[string]string) ([]string, error) {
+	code := strings.TrimSpace(c.FormValue("code"))
+	if code == "" {
+		errs["code"] = "请输入验证码"
+		return nil, nil
+	}
+
+	req := graphql.NewRequest(`mutation ($code: String!) {
+  regenerateRecoveryCodes(Code: $code) {
+    RecoveryCodes
+  }
+}`)
+	req.Var("code", code)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		RegenerateRecoveryCodes struct {
+			RecoveryCodes []string
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.RegenerateRecoveryCodes.RecoveryCodes, nil
+}
+
+// twoFactorQRCode 设置过程中的二维码图片，由服务端生成，密钥不经过第三方
+func twoFactorQRCode(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	secret, _ := sess.Values["totp_secret"].(string)
+	account, _ := sess.Values["totp_account"].(string)
+	if secret == "" {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	q, err := qrcode.Encode(totp.URI(issuer, account, secret))
+	if err != nil {
+		return err
+	}
+	b, err := q.PNG(5)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.Blob(http.StatusOK, "image/png", b)
+}
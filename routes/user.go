@@ -0,0 +1,468 @@
+package routes
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/machinebox/graphql"
+
+	"github.com/mia0x75/venus/g"
+)
+
+// roles 可以分配给用户的角色
+var roles = []struct {
+	Key  string
+	Name string
+}{
+	{"admin", "管理员"},
+	{"reviewer", "审核人"},
+	{"developer", "开发者"},
+}
+
+// avatarTypes 允许上传的头像格式
+var avatarTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+// userNode 用户
+type userNode struct {
+	UUID     string
+	Name     string
+	Email    string
+	Phone    uint64
+	Status   uint8 // 1 正常，0 停用
+	Roles    []string
+	CreateAt uint
+	Avatar   struct {
+		URL string
+	}
+}
+
+func users(c echo.Context) error {
+	return renderUsers(c, nil)
+}
+
+// renderUsers 显示用户列表，reset 为刚刚重置的密码，只在这一次响应中显示
+func renderUsers(c echo.Context, reset map[string]string) error {
+	req := graphql.NewRequest(`query index {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  users (first: 100){
+    edges {
+      node {
+        UUID
+        Name
+        Email
+        Phone
+        Status
+        Roles
+        CreateAt
+        Avatar {
+          URL
+        }
+      }
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me    viewer
+		Users struct {
+			Edges []struct {
+				Node userNode
+			}
+		}
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+
+	names := map[string]string{}
+	for _, r := range roles {
+		names[r.Key] = r.Name
+	}
+
+	return c.Render(http.StatusOK, "users-list.html", map[string]interface{}{
+		"data":     resp,
+		"roles":    names,
+		"saved":    c.QueryParam("saved"),
+		"failures": failures(c),
+		"reset":    reset,
+	})
+}
+
+// avatarUpload 校验通过的头像，文件名取内容的摘要
+type avatarUpload struct {
+	path    string
+	url     string
+	data    []byte
+	created bool // 文件是否由本次上传写入
+}
+
+// readAvatar 读取并校验上传的头像，没有上传时返回 nil，此时还不写入磁盘
+func readAvatar(c echo.Context) (*avatarUpload, error) {
+	fh, err := c.FormFile("avatar")
+	if err == http.ErrMissingFile {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := g.Config().Avatar
+	if fh.Size > cfg.MaxSize*1024 {
+		return nil, fmt.Errorf("头像不能超过 %d KB", cfg.MaxSize)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	ext, ok := avatarTypes[http.DetectContentType(data)]
+	if !ok {
+		return nil, fmt.Errorf("头像只支持 PNG、JPEG 和 GIF 格式")
+	}
+
+	sum := sha1.Sum(data)
+	name := hex.EncodeToString(sum[:]) + ext
+	return &avatarUpload{
+		path: filepath.Join(cfg.Dir, name),
+		url:  cfg.URL + "/" + name,
+		data: data,
+	}, nil
+}
+
+// write 写入头像文件，内容相同的文件已经存在时直接复用
+func (a *avatarUpload) write() error {
+	if a == nil {
+		return nil
+	}
+	if _, err := os.Stat(a.path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(a.path, a.data, 0644); err != nil {
+		return err
+	}
+	a.created = true
+	return nil
+}
+
+// discard 后端保存失败时删除本次写入的文件，已经存在的文件可能被其他用户使用，不删除
+func (a *avatarUpload) discard() {
+	if a != nil && a.created {
+		os.Remove(a.path)
+	}
+}
+
+// editUser 新建或修改用户，校验错误显示在对应的输入框下方
+func editUser(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	uuid := c.Param("uuid")
+	form := map[string]string{
+		"name":     strings.TrimSpace(c.FormValue("name")),
+		"email":    strings.TrimSpace(c.FormValue("email")),
+		"phone":    strings.TrimSpace(c.FormValue("phone")),
+		"password": c.FormValue("password"),
+		"avatar":   c.FormValue("avatar_url"),
+	}
+	selected := map[string]bool{}
+	if c.Request().Method == http.MethodPost {
+		// 只接受预定义的角色
+		for _, r := range roles {
+			for _, v := range c.Request().Form["roles"] {
+				if v == r.Key {
+					selected[r.Key] = true
+				}
+			}
+		}
+	}
+
+	errs := map[string]string{}
+	var message string
+	if c.Request().Method == http.MethodPost {
+		if form["name"] == "" {
+			errs["name"] = "请填写姓名"
+		}
+		if _, err := mail.ParseAddress(form["email"]); err != nil {
+			errs["email"] = "无效的邮箱地址"
+		}
+		var phone uint64
+		if form["phone"] != "" {
+			var err error
+			if phone, err = strconv.ParseUint(form["phone"], 10, 64); err != nil || len(form["phone"]) < 7 {
+				errs["phone"] = "无效的电话号码"
+			}
+		}
+		if (uuid == "" || form["password"] != "") && len(form["password"]) < 8 {
+			errs["password"] = "密码至少需要 8 个字符"
+		}
+		avatar, err := readAvatar(c)
+		if err != nil {
+			errs["avatar"] = err.Error()
+		} else if avatar != nil {
+			form["avatar"] = avatar.url
+		}
+
+		if len(errs) == 0 {
+			var keys []string
+			for _, r := range roles {
+				if selected[r.Key] {
+					keys = append(keys, r.Key)
+				}
+			}
+			input := map[string]interface{}{
+				"Name":   form["name"],
+				"Email":  form["email"],
+				"Phone":  phone,
+				"Roles":  keys,
+				"Avatar": form["avatar"],
+			}
+			if form["password"] != "" {
+				input["Password"] = form["password"]
+			}
+
+			var req *graphql.Request
+			if uuid == "" {
+				req = graphql.NewRequest(`mutation ($input: UserInput!) {
+  createUser(input: $input) {
+    UUID
+  }
+}`)
+			} else {
+				req = graphql.NewRequest(`mutation ($uuid: String! $input: UserInput!) {
+  updateUser(UUID: $uuid, input: $input) {
+    UUID
+  }
+}`)
+				req.Var("uuid", uuid)
+			}
+			req.Var("input", input)
+
+			// set header fields
+			req.Header.Set("Authentication", token)
+			req.Header.Set("Cache-Control", "no-cache")
+
+			var resp struct{}
+			if err := avatar.write(); err != nil {
+				log.Println(err)
+				message = err.Error()
+			} else if err := request(req, &resp); err != nil {
+				log.Println(err)
+				avatar.discard()
+				message = err.Error()
+			} else {
+				return c.Redirect(http.StatusFound, "/users-list.html?saved="+url.QueryEscape(form["name"]))
+			}
+		}
+	}
+
+	req := graphql.NewRequest(`query index ($uuid: String! $fetch: Boolean!) {
+  me {
+    ...UserInfo
+    Badges {
+      Submitted
+      Reviewing
+    }
+    Permissions
+  }
+  user (UUID: $uuid) @include(if: $fetch) {
+    UUID
+    Name
+    Email
+    Phone
+    Status
+    Roles
+    CreateAt
+    Avatar {
+      URL
+    }
+  }
+}
+fragment UserInfo on User {
+  Name
+  UUID
+  Avatar {
+    URL
+  }
+}
+`)
+	req.Var("uuid", uuid)
+	req.Var("fetch", uuid != "")
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		Me   viewer
+		User *userNode
+	}
+
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+	}
+	if uuid != "" && resp.User == nil {
+		return echo.NewHTTPError(http.StatusNotFound)
+	}
+
+	if u := resp.User; u != nil && c.Request().Method != http.MethodPost {
+		form["name"] = u.Name
+		form["email"] = u.Email
+		if u.Phone != 0 {
+			form["phone"] = strconv.FormatUint(u.Phone, 10)
+		}
+		form["avatar"] = u.Avatar.URL
+		for _, r := range u.Roles {
+			selected[r] = true
+		}
+	}
+	// 不回显密码
+	form["password"] = ""
+
+	return c.Render(http.StatusOK, "create-user.html", map[string]interface{}{
+		"data":     resp,
+		"form":     form,
+		"uuid":     uuid,
+		"roles":    roles,
+		"selected": selected,
+		"errors":   errs,
+		"message":  message,
+	})
+}
+
+// password 生成随机密码
+func password(n int) (string, error) {
+	const letters = "abcdefghjkmnpqrstuvwxyzABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	b := make([]byte, n)
+	for i := range b {
+		k, err := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = letters[k.Int64()]
+	}
+	return string(b), nil
+}
+
+// resetPassword 为用户生成新的随机密码，密码直接在本次响应中显示，不经过会话，也不允许缓存
+func resetPassword(c echo.Context) error {
+	sess, _ := session.Get("session", c)
+	token, _ := sess.Values["token"].(string)
+
+	pwd, err := password(12)
+	if err != nil {
+		return err
+	}
+
+	req := graphql.NewRequest(`mutation ($uuid: String! $password: String!) {
+  resetPassword(UUID: $uuid, Password: $password) {
+    Name
+  }
+}`)
+	req.Var("uuid", c.Param("uuid"))
+	req.Var("password", pwd)
+
+	// set header fields
+	req.Header.Set("Authentication", token)
+	req.Header.Set("Cache-Control", "no-cache")
+
+	var resp struct {
+		ResetPassword struct {
+			Name string
+		}
+	}
+	if err := request(req, &resp); err != nil {
+		log.Println(err)
+		fail(c, "重置密码失败: "+err.Error())
+		return c.Redirect(http.StatusFound, "/users-list.html")
+	}
+
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return renderUsers(c, map[string]string{
+		"name":     resp.ResetPassword.Name,
+		"password": pwd,
+	})
+}
+
+// lockoutMutate 停用或删除其他用户，不允许对自己操作以免把自己锁在外面
+func lockoutMutate(c echo.Context, mutation string) error {
+	if current(c).UUID == c.Param("uuid") {
+		fail(c, "不能停用或删除自己的账号")
+		return c.Redirect(http.StatusFound, "/users-list.html")
+	}
+	return mutate(c, "/users-list.html", mutation)
+}
+
+// enableUser 启用用户
+func enableUser(c echo.Context) error {
+	return mutate(c, "/users-list.html", `mutation ($uuid: String!) {
+  enableUser(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
+
+// disableUser 停用用户，停用后不能登录
+func disableUser(c echo.Context) error {
+	return lockoutMutate(c, `mutation ($uuid: String!) {
+  disableUser(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
+
+// removeUser 删除用户
+func removeUser(c echo.Context) error {
+	return lockoutMutate(c, `mutation ($uuid: String!) {
+  removeUser(UUID: $uuid) {
+    UUID
+  }
+}`)
+}
@@ -0,0 +1,315 @@
+package sqlfmt
+
+import (
+	"strings"
+)
+
+// Indent 每一级缩进使用的字符串
+var Indent = "  "
+
+// 另起一行的子句，多个单词组成的子句会先合并成一个词
+var clauses = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "GROUP BY": true, "HAVING": true, "ORDER BY": true,
+	"LIMIT": true, "UNION": true, "UNION ALL": true, "UNION DISTINCT": true, "WINDOW": true,
+	"JOIN": true, "INNER JOIN": true, "CROSS JOIN": true, "STRAIGHT_JOIN": true, "NATURAL JOIN": true,
+	"LEFT JOIN": true, "RIGHT JOIN": true, "LEFT OUTER JOIN": true, "RIGHT OUTER JOIN": true,
+	"INSERT INTO": true, "INSERT IGNORE INTO": true, "REPLACE INTO": true, "VALUES": true, "VALUE": true,
+	"UPDATE": true, "SET": true, "DELETE FROM": true, "ON DUPLICATE KEY UPDATE": true, "WITH": true,
+	"ALTER TABLE": true, "CREATE TABLE": true, "DROP TABLE": true, "TRUNCATE TABLE": true,
+	"ON": true, "USING": true,
+}
+
+// 子句内以逗号分隔、每项单独一行的子句
+var listClauses = map[string]bool{
+	"SELECT": true, "GROUP BY": true, "ORDER BY": true, "SET": true, "ON DUPLICATE KEY UPDATE": true,
+	"ALTER TABLE": true,
+}
+
+// 子句内 AND、OR 另起一行的子句
+var conditionClauses = map[string]bool{
+	"WHERE": true, "HAVING": true, "ON": true,
+}
+
+// 相对子句多缩进一级的子句
+var nestedClauses = map[string]bool{
+	"ON": true, "USING": true,
+}
+
+// 可以与后续单词合并的子句前缀
+var compounds = []string{
+	"ON DUPLICATE KEY UPDATE", "INSERT IGNORE INTO", "LEFT OUTER JOIN", "RIGHT OUTER JOIN",
+	"UNION DISTINCT", "UNION ALL", "GROUP BY", "ORDER BY", "PARTITION BY", "INSERT INTO",
+	"REPLACE INTO", "DELETE FROM", "INNER JOIN", "CROSS JOIN", "NATURAL JOIN", "LEFT JOIN",
+	"RIGHT JOIN", "ALTER TABLE", "CREATE TABLE", "DROP TABLE", "TRUNCATE TABLE",
+}
+
+// word 合并多个关键字之后的词
+type word struct {
+	Token
+	Clause   string // 大写的子句名称，非子句为空
+	Adjacent bool   // 原文中与前一个词之间没有空白
+}
+
+// level 一层括号内的格式化状态
+type level struct {
+	indent int
+	clause string
+	block  bool // 括号内容是否另起一行，子查询和建表语句的字段定义使用块格式
+}
+
+// Format 格式化 SQL：关键字大写，子句另起一行，选择列表、条件和子查询按层级缩进。
+// 注释原样保留，无法识别的语法按原顺序以空格连接，不会丢失任何内容
+func Format(sql string) string {
+	words := merge(Tokenize(sql))
+	var b output
+	stack := []*level{{}}
+	cur := stack[0]
+	newline := func(indent int) {
+		n := b.trimmed()
+		b.buf = b.buf[:n]
+		// 已经在空行上时只调整缩进，避免出现连续的空行
+		if n != b.lineStart {
+			b.writeByte('\n')
+		}
+		b.write(strings.Repeat(Indent, indent))
+	}
+	// 当前是否在行首
+	bol := func() bool {
+		return b.trimmed() == b.lineStart
+	}
+	// 当前行的缩进级数
+	indentOf := func() int {
+		n := 0
+		for _, c := range b.buf[b.lineStart:] {
+			if c != ' ' {
+				break
+			}
+			n++
+		}
+		return n / len(Indent)
+	}
+
+	var prev *word
+	for i := range words {
+		w := &words[i]
+		text := w.Upper()
+		switch {
+		case w.Clause != "":
+			indent := cur.indent
+			if nestedClauses[w.Clause] {
+				indent++
+			}
+			if cur.clause != "" || !bol() || prev != nil && prev.Text == "(" {
+				newline(indent)
+			}
+			cur.clause = w.Clause
+			b.write(w.Clause)
+			b.writeByte(' ')
+		case text == "(":
+			trimSpace(&b)
+			// 函数调用、类型长度以及原文中紧贴名称的括号不加空格
+			if prev != nil && !bol() && prev.Text != "(" && prev.Kind != Operator && !(w.Adjacent && prev.Kind != Punctuation) {
+				b.writeByte(' ')
+			}
+			b.write("(")
+			next := nextWord(words, i)
+			l := &level{indent: cur.indent}
+			if next != nil && (next.Clause == "SELECT" || next.Clause == "WITH") {
+				l.indent = indentOf() + 1
+				l.block = true
+			} else if cur.clause == "CREATE TABLE" && len(stack) == 1 {
+				l.indent = indentOf() + 1
+				l.block = true
+				l.clause = "CREATE TABLE"
+				newline(l.indent)
+			}
+			stack = append(stack, l)
+			cur = l
+		case text == ")":
+			if len(stack) > 1 {
+				if cur.block {
+					newline(cur.indent - 1)
+				} else if !bol() {
+					trimSpace(&b)
+				}
+				stack = stack[:len(stack)-1]
+				cur = stack[len(stack)-1]
+			}
+			b.write(")")
+			b.writeByte(' ')
+		case text == ",":
+			trimSpace(&b)
+			b.write(",")
+			if cur.block && cur.clause == "CREATE TABLE" {
+				// 建表语句的字段定义每个一行
+				newline(cur.indent)
+			} else if listClauses[cur.clause] {
+				newline(cur.indent + 1)
+			} else {
+				b.writeByte(' ')
+			}
+		case text == ";":
+			trimSpace(&b)
+			b.write(";\n")
+			stack = stack[:1]
+			cur = stack[0]
+			cur.clause = ""
+		case text == ".":
+			trimSpace(&b)
+			b.write(".")
+		case (text == "AND" || text == "OR" || text == "XOR") && conditionClauses[cur.clause] && !betweenAnd(words, i):
+			indent := cur.indent + 1
+			if nestedClauses[cur.clause] {
+				indent++
+			}
+			newline(indent)
+			b.write(text)
+			b.writeByte(' ')
+		case w.Kind == Comment:
+			trimSpace(&b)
+			if !bol() {
+				b.writeByte(' ')
+			}
+			b.write(strings.TrimRight(w.Text, "\n"))
+			if strings.HasPrefix(w.Text, "/*") {
+				b.writeByte(' ')
+			} else {
+				// 单行注释之后必须换行，保持当前行的缩进
+				newline(indentOf())
+			}
+			continue
+		default:
+			if prev != nil && prev.Text == "." {
+				trimSpace(&b)
+			}
+			if listClauses[cur.clause] && prev != nil && prev.Clause == cur.clause && cur.clause != "ALTER TABLE" {
+				// 选择列表的第一项也单独一行
+				newline(cur.indent + 1)
+			}
+			b.write(text)
+			b.writeByte(' ')
+		}
+		prev = w
+	}
+	return strings.TrimSpace(string(b.buf))
+}
+
+// merge 去掉空白，并把 GROUP BY、LEFT JOIN 这类由多个关键字组成的子句合并成一个词
+func merge(tokens []Token) []word {
+	var words []word
+	adjacent := false
+	for _, t := range tokens {
+		if t.Kind == Space {
+			adjacent = false
+			continue
+		}
+		words = append(words, word{Token: t, Adjacent: adjacent})
+		adjacent = true
+	}
+	var merged []word
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if w.Kind == Keyword {
+			for _, c := range compounds {
+				parts := strings.Fields(c)
+				if i+len(parts) > len(words) {
+					continue
+				}
+				ok := true
+				for j, p := range parts {
+					if words[i+j].Kind != Keyword || strings.ToUpper(words[i+j].Text) != p {
+						ok = false
+						break
+					}
+				}
+				if ok {
+					w.Text = c
+					i += len(parts) - 1
+					break
+				}
+			}
+			if clauses[w.Upper()] {
+				w.Clause = w.Upper()
+			}
+		}
+		merged = append(merged, w)
+	}
+	return merged
+}
+
+func nextWord(words []word, i int) *word {
+	for j := i + 1; j < len(words); j++ {
+		if words[j].Kind != Comment {
+			return &words[j]
+		}
+	}
+	return nil
+}
+
+// betweenAnd 判断 AND 是否属于 BETWEEN ... AND ...
+func betweenAnd(words []word, i int) bool {
+	if strings.ToUpper(words[i].Text) != "AND" {
+		return false
+	}
+	depth := 0
+	for j := i - 1; j >= 0; j-- {
+		switch strings.ToUpper(words[j].Text) {
+		case ")":
+			depth++
+		case "(":
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case "BETWEEN":
+			if depth == 0 {
+				return true
+			}
+		case "AND", "OR", "XOR":
+			if depth == 0 {
+				return false
+			}
+		}
+		if words[j].Clause != "" && depth == 0 {
+			return false
+		}
+	}
+	return false
+}
+
+// output 格式化的结果，记录当前行的起始位置，去掉行尾空格时直接截断，不需要复制已经输出的内容
+type output struct {
+	buf       []byte
+	lineStart int
+}
+
+func (o *output) write(s string) {
+	o.buf = append(o.buf, s...)
+	// 多行的注释和字符串中也可能有换行
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		o.lineStart = len(o.buf) - len(s) + i + 1
+	}
+}
+
+func (o *output) writeByte(c byte) {
+	o.buf = append(o.buf, c)
+	if c == '\n' {
+		o.lineStart = len(o.buf)
+	}
+}
+
+// trimmed 去掉当前行行尾空格之后的长度
+func (o *output) trimmed() int {
+	n := len(o.buf)
+	for n > o.lineStart && o.buf[n-1] == ' ' {
+		n--
+	}
+	return n
+}
+
+// trimSpace 去掉行尾空格，行首的缩进保留
+func trimSpace(b *output) {
+	if n := b.trimmed(); n != b.lineStart {
+		b.buf = b.buf[:n]
+	}
+}
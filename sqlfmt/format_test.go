@@ -0,0 +1,61 @@
+package sqlfmt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{
+			"select a, status from t where status = 1 and b between 1 and 2 order by a desc limit 10",
+			"SELECT\n  a,\n  status\nFROM t\nWHERE status = 1\n  AND b BETWEEN 1 AND 2\nORDER BY\n  a DESC\nLIMIT 10",
+		},
+		{
+			"select * from t1 left join t2 on t1.id = t2.id and t2.x > 1",
+			"SELECT\n  *\nFROM t1\nLEFT JOIN t2\n  ON t1.id = t2.id\n    AND t2.x > 1",
+		},
+		{
+			"select a from (select b from c) x",
+			"SELECT\n  a\nFROM (\n  SELECT\n    b\n  FROM c\n) x",
+		},
+		{
+			"create table t (id int, `status` tinyint, primary key (id)) engine=innodb",
+			"CREATE TABLE t (\n  id INT,\n  `status` TINYINT,\n  PRIMARY KEY (id)\n) ENGINE = innodb",
+		},
+		{
+			"select a -- note\nfrom t",
+			"SELECT\n  a -- note\nFROM t",
+		},
+		{
+			"update t set a = 1; delete from t",
+			"UPDATE t\nSET\n  a = 1;\nDELETE FROM t",
+		},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.sql); got != tt.want {
+			t.Errorf("Format(%q)\ngot:\n%s\nwant:\n%s", tt.sql, got, tt.want)
+		}
+	}
+}
+
+// 行数很多时格式化的耗时应当与长度成线性关系
+func TestFormatLong(t *testing.T) {
+	sql := "SELECT " + strings.Repeat("a, ", 20000) + "b FROM t"
+	got := Format(sql)
+	if n := strings.Count(got, "\n"); n != 20002 {
+		t.Errorf("Format 输出 %d 行, want 20003", n+1)
+	}
+}
+
+func TestInlineKeepsCase(t *testing.T) {
+	got := string(Inline("select status from t"))
+	for _, s := range []string{">select<", ">status<", ">from<", `"sql-identifier">status`} {
+		if !strings.Contains(got, s) {
+			t.Errorf("Inline 输出 %s 中没有 %s", got, s)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package sqlfmt
+
+import (
+	"html"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+// classes 词法单元类型对应的 CSS 类名，样式见 public/assets/css/sql.css
+var classes = map[Kind]string{
+	Comment:          "sql-comment",
+	Keyword:          "sql-keyword",
+	Function:         "sql-function",
+	Identifier:       "sql-identifier",
+	QuotedIdentifier: "sql-identifier",
+	String:           "sql-string",
+	Number:           "sql-number",
+	Variable:         "sql-variable",
+	Operator:         "sql-operator",
+}
+
+// HTML 格式化并高亮 SQL，返回带行号的表格
+func HTML(sql string) template.HTML {
+	return Lines(Format(sql))
+}
+
+// Lines 高亮 SQL 但不改变原有排版，返回带行号的表格
+func Lines(sql string) template.HTML {
+	var b strings.Builder
+	b.WriteString(`<table class="sql-code"><tbody>`)
+	n := 0
+	open := func() {
+		n++
+		b.WriteString(`<tr><td class="sql-ln">`)
+		b.WriteString(strconv.Itoa(n))
+		b.WriteString(`</td><td class="sql-line">`)
+	}
+	closeLine := func() {
+		b.WriteString(`</td></tr>`)
+	}
+	open()
+	for _, t := range Tokenize(strings.TrimSpace(sql)) {
+		// 多行的注释或字符串需要在每一行分别闭合标签
+		for i, part := range strings.Split(t.Text, "\n") {
+			if i > 0 {
+				closeLine()
+				open()
+			}
+			span(&b, t, part)
+		}
+	}
+	closeLine()
+	b.WriteString(`</tbody></table>`)
+	return template.HTML(b.String())
+}
+
+// Inline 高亮单行 SQL，不格式化也不显示行号，适合在列表中显示
+func Inline(sql string) template.HTML {
+	var b strings.Builder
+	b.WriteString(`<code class="sql-inline">`)
+	for _, t := range Tokenize(sql) {
+		if t.Kind == Space {
+			b.WriteByte(' ')
+			continue
+		}
+		span(&b, t, t.Text)
+	}
+	b.WriteString(`</code>`)
+	return template.HTML(b.String())
+}
+
+// span 输出一个高亮的词法单元，只高亮不改变大小写，大写由 Format 负责
+func span(b *strings.Builder, t Token, text string) {
+	if text == "" {
+		return
+	}
+	class, ok := classes[t.Kind]
+	if !ok {
+		b.WriteString(html.EscapeString(text))
+		return
+	}
+	b.WriteString(`<span class="`)
+	b.WriteString(class)
+	b.WriteString(`">`)
+	b.WriteString(html.EscapeString(text))
+	b.WriteString(`</span>`)
+}
@@ -0,0 +1,97 @@
+package sqlfmt
+
+import (
+	"strings"
+)
+
+// keywords MySQL 保留字以及格式化时需要识别的常用非保留字
+var keywords = map[string]bool{}
+
+// functions 同时也是关键字的函数名，紧跟括号时按函数处理
+var functions = map[string]bool{}
+
+// nonReserved 非保留字，可以不加引号直接用作表名或列名，在名称的位置上按标识符处理
+var nonReserved = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`
+		ACCESSIBLE ADD AFTER AGAINST ALGORITHM ALL ALTER ANALYZE AND AS ASC ASENSITIVE AUTO_INCREMENT
+		BEFORE BEGIN BETWEEN BIGINT BINARY BLOB BOTH BTREE BY CALL CASCADE CASE CHANGE CHAR CHARACTER
+		CHARSET CHECK COLLATE COLUMN COLUMNS COMMENT COMMIT CONSTRAINT CONTINUE CONVERT CREATE CROSS
+		CURRENT_DATE CURRENT_TIME CURRENT_TIMESTAMP CURRENT_USER CURSOR DATABASE DATABASES DATE DATETIME
+		DAY DAY_HOUR DAY_MINUTE DAY_SECOND DEC DECIMAL DECLARE DEFAULT DELAYED DELETE DESC DESCRIBE
+		DETERMINISTIC DISTINCT DISTINCTROW DIV DOUBLE DROP DUAL DUPLICATE EACH ELSE ELSEIF ENCLOSED END
+		ENGINE ENUM ESCAPE ESCAPED EXISTS EXIT EXPLAIN FALSE FETCH FIELDS FIRST FLOAT FOR FORCE FOREIGN FORMAT
+		FROM FULL FULLTEXT FUNCTION GENERATED GRANT GROUP HASH HAVING HIGH_PRIORITY HOUR IF IGNORE INDEX
+		IN INFILE INNER INOUT INSENSITIVE INSERT INT INTEGER INTERVAL INTO IS ITERATE JOIN JSON KEY
+		KEYS KILL LEADING LEAVE LEFT LIKE LIMIT LINEAR LINES LOAD LOCALTIME LOCALTIMESTAMP LOCK LONGBLOB
+		LONGTEXT LOOP LOW_PRIORITY MATCH MEDIUMBLOB MEDIUMINT MEDIUMTEXT MINUTE MOD MODE MODIFY MONTH
+		NATURAL NOT NO_WRITE_TO_BINLOG NULL NUMERIC OFFSET ON OPTIMIZE OPTION OPTIONALLY OR ORDER OUT
+		OUTER OUTFILE PARTITION PRECISION PRIMARY PROCEDURE PURGE RANGE READ REAL REFERENCES REGEXP
+		RELEASE RENAME REPEAT REPLACE REQUIRE RESTRICT RETURN REVOKE RIGHT RLIKE ROLLBACK ROLLUP ROW ROWS
+		SCHEMA SCHEMAS SECOND SELECT SEPARATOR SET SHARE SHOW SIGNAL SMALLINT SPATIAL SQL_BIG_RESULT
+		SQL_CALC_FOUND_ROWS SQL_NO_CACHE SQL_SMALL_RESULT SSL START STARTING STATUS STORED STRAIGHT_JOIN
+		TABLE TABLES TEMPORARY TERMINATED TEXT THEN TIME TIMESTAMP TINYBLOB TINYINT TINYTEXT TO TRAILING
+		TRANSACTION TRIGGER TRUE TRUNCATE UNDO UNION UNIQUE UNLOCK UNSIGNED UPDATE USAGE USE USING
+		UTC_DATE UTC_TIME UTC_TIMESTAMP VALUE VALUES VARBINARY VARCHAR VARYING VIEW VIRTUAL WHEN WHERE
+		WHILE WINDOW WITH WRITE XOR YEAR YEAR_MONTH ZEROFILL OVER PARTITION RECURSIVE LATERAL
+	`) {
+		keywords[k] = true
+	}
+	for _, f := range strings.Fields(`
+		CHAR CONVERT CURRENT_DATE CURRENT_TIME CURRENT_TIMESTAMP CURRENT_USER DATABASE DATE DAY HOUR IF
+		INSERT INTERVAL LEFT LOCALTIME LOCALTIMESTAMP MINUTE MOD MONTH REPEAT REPLACE RIGHT SCHEMA SECOND
+		TIME TIMESTAMP TRUNCATE UTC_DATE UTC_TIME UTC_TIMESTAMP YEAR
+	`) {
+		functions[f] = true
+	}
+	for _, k := range strings.Fields(`
+		AFTER AGAINST ALGORITHM AUTO_INCREMENT BEGIN BTREE CHARSET COLUMNS COMMENT COMMIT DATE DATETIME
+		DAY END ENGINE ENUM ESCAPE FIELDS FIRST FORMAT FULL HASH HOUR JSON MINUTE MODE MODIFY MONTH
+		OFFSET ROLLBACK SECOND SHARE START STATUS TABLES TEMPORARY TEXT TIME TIMESTAMP TRANSACTION
+		TRUNCATE VALUE VIEW YEAR
+	`) {
+		nonReserved[k] = true
+	}
+}
+
+// nameKeywords 其后紧跟的通常是列名或表名，出现在这些关键字之后的非保留字按标识符处理
+var nameKeywords = map[string]bool{
+	"SELECT":   true,
+	"DISTINCT": true,
+	"FROM":     true,
+	"JOIN":     true,
+	"INTO":     true,
+	"UPDATE":   true,
+	"SET":      true,
+	"WHERE":    true,
+	"AND":      true,
+	"OR":       true,
+	"NOT":      true,
+	"ON":       true,
+	"BY":       true,
+	"HAVING":   true,
+	"WHEN":     true,
+	"THEN":     true,
+}
+
+// objectKeywords 其后紧跟的是表名或索引名，即使名称后面紧跟括号也不是函数调用
+var objectKeywords = map[string]bool{
+	"FROM":       true,
+	"INDEX":      true,
+	"INTO":       true,
+	"JOIN":       true,
+	"KEY":        true,
+	"ON":         true,
+	"REFERENCES": true,
+	"TABLE":      true,
+	"UPDATE":     true,
+}
+
+func isKeyword(word string) bool {
+	return keywords[strings.ToUpper(word)]
+}
+
+func isFunction(word string) bool {
+	return functions[strings.ToUpper(word)]
+}
@@ -0,0 +1,211 @@
+package sqlfmt
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kind 词法单元类型
+type Kind int
+
+// 词法单元类型
+const (
+	Space Kind = iota
+	Comment
+	Keyword
+	Function
+	Identifier
+	QuotedIdentifier
+	String
+	Number
+	Variable
+	Operator
+	Punctuation
+)
+
+// Token 词法单元
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// Upper 返回关键字的大写形式，其余类型原样返回
+func (t Token) Upper() string {
+	if t.Kind == Keyword {
+		return strings.ToUpper(t.Text)
+	}
+	return t.Text
+}
+
+// Tokenize 按 MySQL 方言拆分 SQL，任何输入都能拆分，未闭合的字符串或注释一直延续到结尾，
+// 所有词法单元的 Text 拼接起来与输入完全一致
+func Tokenize(sql string) []Token {
+	var tokens []Token
+	rs := []rune(sql)
+	for i := 0; i < len(rs); {
+		start := i
+		kind := Punctuation
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			kind = Space
+			for i < len(rs) && unicode.IsSpace(rs[i]) {
+				i++
+			}
+		case r == '#' || (r == '-' && i+1 < len(rs) && rs[i+1] == '-' && (i+2 == len(rs) || unicode.IsSpace(rs[i+2]))):
+			kind = Comment
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			kind = Comment
+			for i += 2; i < len(rs) && !(rs[i] == '/' && rs[i-1] == '*' && i-1 > start+1); i++ {
+			}
+			if i < len(rs) {
+				i++
+			}
+		case r == '\'' || r == '"':
+			kind = String
+			i = quoted(rs, i, r, true)
+		case r == '`':
+			kind = QuotedIdentifier
+			i = quoted(rs, i, r, false)
+		case r == '@':
+			kind = Variable
+			for i++; i < len(rs) && (rs[i] == '@' || isWord(rs[i]) || rs[i] == '.'); i++ {
+			}
+			if i < len(rs) && (rs[i] == '\'' || rs[i] == '"' || rs[i] == '`') && i == start+1 {
+				i = quoted(rs, i, rs[i], rs[i] != '`')
+			}
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1]) && !afterWord(tokens)):
+			kind = Number
+			i = number(rs, i)
+		case isWord(r):
+			for i < len(rs) && isWord(rs[i]) {
+				i++
+			}
+			word := string(rs[start:i])
+			kind = Identifier
+			if isKeyword(word) && !(nonReserved[strings.ToUpper(word)] && usedAsName(tokens, rs, i)) {
+				kind = Keyword
+			}
+			// MySQL 默认要求函数名与括号之间没有空格
+			if i < len(rs) && rs[i] == '(' && (kind == Identifier || isFunction(word)) && !afterObjectKeyword(tokens) {
+				kind = Function
+			}
+			// ON DUPLICATE KEY UPDATE a = VALUES(a) 中的 VALUES 是函数
+			if i < len(rs) && rs[i] == '(' && strings.EqualFold(word, "VALUES") && afterOperator(tokens) {
+				kind = Function
+			}
+		case strings.ContainsRune("=<>!|&+-*/%^~:", r):
+			kind = Operator
+			for i++; i < len(rs) && strings.ContainsRune("=<>!|&:", rs[i]); i++ {
+			}
+		default:
+			i++
+		}
+		tokens = append(tokens, Token{Kind: kind, Text: string(rs[start:i])})
+	}
+	return tokens
+}
+
+func quoted(rs []rune, i int, q rune, escape bool) int {
+	for i++; i < len(rs); i++ {
+		if escape && rs[i] == '\\' {
+			i++
+			continue
+		}
+		if rs[i] == q {
+			// 连续两个引号表示转义
+			if i+1 < len(rs) && rs[i+1] == q {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(rs)
+}
+
+func number(rs []rune, i int) int {
+	if rs[i] == '0' && i+1 < len(rs) && (rs[i+1] == 'x' || rs[i+1] == 'X' || rs[i+1] == 'b' || rs[i+1] == 'B') {
+		for i += 2; i < len(rs) && (unicode.IsDigit(rs[i]) || strings.ContainsRune("abcdefABCDEF", rs[i])); i++ {
+		}
+		return i
+	}
+	for ; i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.'); i++ {
+	}
+	if i < len(rs) && (rs[i] == 'e' || rs[i] == 'E') {
+		j := i + 1
+		if j < len(rs) && (rs[j] == '+' || rs[j] == '-') {
+			j++
+		}
+		if j < len(rs) && unicode.IsDigit(rs[j]) {
+			for i = j; i < len(rs) && unicode.IsDigit(rs[i]); i++ {
+			}
+		}
+	}
+	return i
+}
+
+func isWord(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// afterObjectKeyword 判断前一个有效词法单元是否为 INTO、TABLE 等引出对象名的关键字
+func afterObjectKeyword(tokens []Token) bool {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		switch tokens[i].Kind {
+		case Space, Comment:
+			continue
+		case Keyword:
+			return objectKeywords[strings.ToUpper(tokens[i].Text)]
+		}
+		return false
+	}
+	return false
+}
+
+// usedAsName 判断刚拆分出的非保留字是否处在名称的位置上，例如 "t.status"、"SELECT status"、
+// "WHERE status = 1" 以及列表中逗号之后的项，end 为这个词之后的位置
+func usedAsName(tokens []Token, rs []rune, end int) bool {
+	if end < len(rs) && rs[end] == '.' {
+		return true
+	}
+	for i := len(tokens) - 1; i >= 0; i-- {
+		switch tokens[i].Kind {
+		case Space, Comment:
+			continue
+		case Keyword:
+			return nameKeywords[strings.ToUpper(tokens[i].Text)]
+		case Operator:
+			return true
+		case Punctuation:
+			return tokens[i].Text == "." || tokens[i].Text == "," || tokens[i].Text == "("
+		}
+		return false
+	}
+	return false
+}
+
+// afterOperator 判断前一个有效词法单元是否为运算符
+func afterOperator(tokens []Token) bool {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].Kind != Space && tokens[i].Kind != Comment {
+			return tokens[i].Kind == Operator
+		}
+	}
+	return false
+}
+
+// afterWord 判断 "t.1col" 这类限定名中的点号，避免把 ".1" 当作数字
+func afterWord(tokens []Token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch tokens[len(tokens)-1].Kind {
+	case Identifier, QuotedIdentifier:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package sqlfmt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []Kind // 不含空白
+	}{
+		{"SELECT 1", []Kind{Keyword, Number}},
+		{"select `a`, 'x''y' from t", []Kind{Keyword, QuotedIdentifier, Punctuation, String, Keyword, Identifier}},
+		{"count(*) >= @v", []Kind{Function, Punctuation, Operator, Punctuation, Operator, Variable}},
+		{"a -- comment\n/* c */ b", []Kind{Identifier, Comment, Comment, Identifier}},
+		{"t.1col + .5e3", []Kind{Identifier, Punctuation, Number, Identifier, Operator, Number}},
+		{"FROM t(a)", []Kind{Keyword, Identifier, Punctuation, Identifier, Punctuation}},
+		{"SELECT status FROM t WHERE status = 1", []Kind{Keyword, Identifier, Keyword, Identifier, Keyword, Identifier, Operator, Number}},
+		{"SHOW TABLE STATUS", []Kind{Keyword, Keyword, Keyword}},
+		{"CAST(x AS DATE)", []Kind{Function, Punctuation, Identifier, Keyword, Keyword, Punctuation}},
+		{"'unterminated", []Kind{String}},
+	}
+	for _, tt := range tests {
+		tokens := Tokenize(tt.sql)
+		var text strings.Builder
+		var kinds []Kind
+		for _, tok := range tokens {
+			text.WriteString(tok.Text)
+			if tok.Kind != Space {
+				kinds = append(kinds, tok.Kind)
+			}
+		}
+		if text.String() != tt.sql {
+			t.Errorf("Tokenize(%q) 拼接结果为 %q", tt.sql, text.String())
+		}
+		if len(kinds) != len(tt.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", tt.sql, kinds, tt.want)
+			continue
+		}
+		for i := range kinds {
+			if kinds[i] != tt.want[i] {
+				t.Errorf("Tokenize(%q) = %v, want %v", tt.sql, kinds, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 中 SHA1 的测试向量，取 8 位结果的后 6 位
+var secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := Code(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Code(%d)=%s，应为 %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111109, 0)
+	tests := []struct {
+		passcode string
+		t        time.Time
+		want     bool
+	}{
+		{"081804", at, true},
+		{"081 804", at, true},
+		{" 081804 ", at, true},
+		{"081804", at.Add(period * time.Second), true},
+		{"081804", at.Add(-period * time.Second), true},
+		{"081804", at.Add(2 * period * time.Second), false},
+		{"081805", at, false},
+		{"81804", at, false},
+		{"", at, false},
+	}
+	for _, tt := range tests {
+		if got := Validate(secret, tt.passcode, tt.t); got != tt.want {
+			t.Errorf("Validate(%q, %d)=%v，应为 %v", tt.passcode, tt.t.Unix(), got, tt.want)
+		}
+	}
+	if Validate("not base32!", "081804", at) {
+		t.Error("无效的密钥不应通过校验")
+	}
+}
+
+func TestSecret(t *testing.T) {
+	s, err := Secret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 32 {
+		t.Errorf("密钥长度为 %d，应为 32", len(s))
+	}
+	if _, err := Code(s, time.Now()); err != nil {
+		t.Errorf("生成的密钥无法使用: %v", err)
+	}
+}